@@ -5,9 +5,13 @@ go 1.16
 require (
 	github.com/gobuffalo/buffalo v1.1.0
 	github.com/gobuffalo/envy v1.10.2
+	github.com/gobuffalo/github_flavored_markdown v1.1.3
 	github.com/gobuffalo/httptest v1.5.2
+	github.com/gobuffalo/logger v1.0.7
 	github.com/nicksnyder/go-i18n v1.10.1
 	github.com/sirupsen/logrus v1.9.0
 	github.com/stretchr/testify v1.8.1
 	github.com/unrolled/secure v1.13.0
+	golang.org/x/text v0.6.0
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,34 @@
+package i18n
+
+import (
+	"github.com/nicksnyder/go-i18n/i18n/language"
+	xlanguage "golang.org/x/text/language"
+)
+
+// The Unicode characters isolating a left-to-right text within a text of
+// another direction, see BidiIsolates.
+const (
+	leftToRightIsolate    = "\u2066"
+	popDirectionalIsolate = "\u2069"
+)
+
+// isRTL tells whether lang is written from right to left, see
+// Locale.Direction.
+func isRTL(lang *language.Language) bool {
+	tag, err := xlanguage.Parse(lang.Tag)
+	if err != nil {
+		return false
+	}
+	return Locale{Tag: tag}.Direction() == "rtl"
+}
+
+// isolateStrings wraps the strings of data in left-to-right isolates, for
+// the BidiIsolates option, so that an English name doesn't garble the
+// Arabic sentence around it.
+func isolateStrings(data map[string]interface{}) {
+	for k, v := range data {
+		if s, ok := v.(string); ok && s != "" {
+			data[k] = leftToRightIsolate + s + popDirectionalIsolate
+		}
+	}
+}
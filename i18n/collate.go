@@ -0,0 +1,19 @@
+package i18n
+
+import (
+	"golang.org/x/text/collate"
+	xlanguage "golang.org/x/text/language"
+)
+
+// SortLocalized sorts strs in place, in the alphabetical order of lang:
+// "ä" sorts with "a" in German, but after "z" in Swedish. Use it to sort
+// translated strings, which byte order sorts wrongly as soon as they have
+// accents or a non-Latin script. The root order is used for an unknown
+// language.
+func SortLocalized(lang string, strs []string) {
+	tag, err := xlanguage.Parse(lang)
+	if err != nil {
+		tag = xlanguage.Und
+	}
+	collate.New(tag).SortStrings(strs)
+}
@@ -0,0 +1,20 @@
+package i18n
+
+import (
+	"golang.org/x/text/currency"
+	xlanguage "golang.org/x/text/language"
+	xmessage "golang.org/x/text/message"
+)
+
+// FormatLocalCurrency formats amount in the currency of lang, as used in its
+// region: "en-US" formats US dollars ("$ 1,234.50"), "ja-JP" Japanese yen ("￥ 1,235").
+// The region is guessed for a language without one, e.g. "fr" uses the euro.
+// A currency can be forced with the "cu" extension: "en-US-u-cu-eur".
+func FormatLocalCurrency(lang string, amount float64) string {
+	tag, err := xlanguage.Parse(lang)
+	if err != nil {
+		tag = xlanguage.Und
+	}
+	unit, _ := currency.FromTag(tag)
+	return xmessage.NewPrinter(tag).Sprint(currency.Symbol(unit.Amount(amount)))
+}
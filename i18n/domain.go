@@ -0,0 +1,72 @@
+package i18n
+
+import (
+	"strings"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/nicksnyder/go-i18n/i18n/translation"
+)
+
+// domainSeparator separates the domain from the ID of the messages of a
+// domain, see Domains.
+const domainSeparator = ":"
+
+// domainID returns the ID a message of domain is loaded with.
+func domainID(domain, id string) string {
+	return domain + domainSeparator + id
+}
+
+// fileDomain returns the domain of the locale file at path, see Domains, or
+// an empty string if it isn't in the directory of a domain.
+func (t *Translator) fileDomain(path string) string {
+	i := strings.Index(path, "/")
+	if i < 0 {
+		return ""
+	}
+	for _, domain := range t.Domains {
+		if path[:i] == domain {
+			return domain
+		}
+	}
+	return ""
+}
+
+// inDomain returns the messages of lf with the IDs of domain.
+func (lf *localeFile) inDomain(domain string) *localeFile {
+	same := func(s string) string { return s }
+	translations := make([]translation.Translation, 0, len(lf.translations))
+	for _, tr := range lf.translations {
+		translations = append(translations, rewriteTranslation(tr, domainID(domain, tr.ID()), same))
+	}
+	descriptions := make(map[string]string, len(lf.descriptions))
+	for id, desc := range lf.descriptions {
+		descriptions[domainID(domain, id)] = desc
+	}
+	origins := make(map[string]string, len(lf.origins))
+	for id, origin := range lf.origins {
+		origins[domainID(domain, id)] = origin
+	}
+	return &localeFile{
+		lang:         lf.lang,
+		translations: translations,
+		descriptions: descriptions,
+		includes:     lf.includes,
+		origins:      origins,
+	}
+}
+
+// TranslateDomain returns the translation of the string identified by
+// translationID in domain, see Domains: the message of the domain when it
+// is translated in the language of c, the shared one otherwise. The same
+// ID can then be worded differently in the emails and on the web.
+//
+//	subject := transl.TranslateDomain(c, "emails", "welcome")
+//
+// See Translate for further details.
+func (t *Translator) TranslateDomain(c buffalo.Context, domain, translationID string, args ...interface{}) string {
+	id := domainID(domain, translationID)
+	if lang := t.contextLanguage(c); lang != nil && t.lookup(lang, t.messageID(id)) != nil {
+		return t.Translate(c, id, args...)
+	}
+	return t.Translate(c, translationID, args...)
+}
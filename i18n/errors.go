@@ -0,0 +1,41 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gobuffalo/buffalo"
+)
+
+// Localizable is implemented by the errors (or any other value) carrying
+// the message to show to the users, see TranslateError.
+type Localizable interface {
+	// TranslationID identifies the message.
+	TranslationID() string
+	// TemplateData is the template data of the message, or nil.
+	TemplateData() interface{}
+}
+
+// TranslateError returns the translation of the message of err, in the
+// language of the context, if err or one of the errors it wraps is
+// Localizable. Otherwise, or if the message has no translation, it returns
+// an error along with err.Error(), so the caller decides what to show. A
+// nil err has nothing to show: an empty string is returned, without error.
+func (t *Translator) TranslateError(c buffalo.Context, err error) (string, error) {
+	if err == nil {
+		return "", nil
+	}
+	var l Localizable
+	if !errors.As(err, &l) {
+		return err.Error(), fmt.Errorf("i18n: error %T is not Localizable", err)
+	}
+	var args []interface{}
+	if data := l.TemplateData(); data != nil {
+		args = append(args, data)
+	}
+	s, terr := t.translateChecked(c, l.TranslationID(), args...)
+	if terr != nil {
+		return err.Error(), terr
+	}
+	return s, nil
+}
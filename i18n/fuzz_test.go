@@ -0,0 +1,52 @@
+//go:build go1.18
+// +build go1.18
+
+package i18n_test
+
+import (
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gobuffalo/middleware/i18n"
+)
+
+var wellFormedTag = regexp.MustCompile(`^[A-Za-z]{1,8}(-[A-Za-z0-9]{1,8})*$`)
+
+func FuzzParseAcceptLanguage(f *testing.F) {
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	for _, seed := range []string{
+		"",
+		"fr-fr",
+		"de;q=0.5, en-US;q=0.9, fr;q=0.8, es;q=0, it",
+		"en-US-u-ca-gregory, x-klingon",
+		"fr;q=abc, de;q=, it;q=NaN, es;q=-1, nl;q=2, pt;q=1e-1",
+		";;;,,,;q=0.5,-,--,en-",
+		"fr\x00-fr, \x00, de\x00;q=0.5",
+		"*, *;q=0.1",
+		strings.Repeat("en-US,", 10000),
+		strings.Repeat("a", 100000),
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, header string) {
+		req := &http.Request{Header: http.Header{"Accept-Language": []string{header}}}
+		langs := transl.NegotiateRequest(req)
+		withDefault := false
+		for _, lang := range langs {
+			if !wellFormedTag.MatchString(lang) {
+				t.Fatalf("%q: invalid language %q", header, lang)
+			}
+			withDefault = withDefault || strings.EqualFold(lang, "en-US")
+		}
+		if !withDefault {
+			t.Fatalf("%q: the default language is missing from %q", header, langs)
+		}
+	})
+}
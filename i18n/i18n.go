@@ -1,16 +1,32 @@
 package i18n
 
 import (
+	"context"
+	"embed"
 	"fmt"
+	"io"
 	"io/fs"
+	"math"
+	"net/http"
+	"net/url"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/logger"
 	"github.com/nicksnyder/go-i18n/i18n"
+	"github.com/nicksnyder/go-i18n/i18n/bundle"
 	"github.com/nicksnyder/go-i18n/i18n/language"
 	"github.com/nicksnyder/go-i18n/i18n/translation"
+	xlanguage "golang.org/x/text/language"
+	xdisplay "golang.org/x/text/language/display"
 )
 
 // LanguageExtractor can be implemented for custom finding of search
@@ -19,10 +35,73 @@ import (
 // on how the default implementation searches for languages.
 type LanguageExtractor func(LanguageExtractorOptions, buffalo.Context) []string
 
+// ConflictPolicy tells which message to keep when a message is defined
+// twice for the same language: by several locale files (in path order), or
+// by Merge.
+type ConflictPolicy int
+
+const (
+	// LastWins keeps the message added last. This is the default.
+	LastWins ConflictPolicy = iota
+	// FirstWins keeps the message added first.
+	FirstWins
+	// ErrorOnConflict keeps the message added first, as FirstWins, but
+	// Load and Merge report the conflicts as errors.
+	ErrorOnConflict
+)
+
 // LanguageExtractorOptions is a map of options for a LanguageExtractor.
 type LanguageExtractorOptions map[string]interface{}
 
+// AvailableLanguagesOption is a reserved LanguageExtractorOptions key. It is
+// set, before calling the extractors, to the []string of the languages
+// available in the app (see AvailableLanguages), so an extractor can make
+// choices based on the supported languages.
+const AvailableLanguagesOption = "AvailableLanguages"
+
+// LogLevelOption is the LanguageExtractorOptions key of the level (a
+// logger.Level) at which the extractors report their missing options, such
+// as "CookieName". default is logger.WarnLevel.
+const LogLevelOption = "LogLevel"
+
+// SessionValueOption is the LanguageExtractorOptions key of an optional
+// func(interface{}) string, used by SessionLanguageExtractor to get the
+// language out of a session value that isn't a string, such as a struct
+// of user preferences.
+const SessionValueOption = "SessionValue"
+
+// WeightedLanguage is a language tag, with the weight of the user's
+// preference for it. Higher weights are preferred.
+type WeightedLanguage struct {
+	Tag    string
+	Weight float64
+}
+
+// WeightedLanguageExtractor is like a LanguageExtractor, but each language
+// found comes with a weight. The results of all the weighted extractors are
+// merged and sorted by weight, rather than by extractor position.
+type WeightedLanguageExtractor func(LanguageExtractorOptions, buffalo.Context) []WeightedLanguage
+
+// LanguageSource is a language of the user, along with where it comes from.
+// The middleware sets the languages of the request as the "languageSources"
+// context value, next to the plain "languages" tags.
+type LanguageSource struct {
+	Tag string
+	// Source is the name of the extractor that found the language, such as
+	// "i18n.CookieLanguageExtractor", or "default" for DefaultLanguage.
+	Source string
+	// Weight is the weight given by a WeightedLanguageExtractor, 0 for the
+	// other extractors.
+	Weight float64
+}
+
 // Translator for handling all your i18n needs.
+//
+// Each Translator translates with its own messages, so that translators
+// loading different files don't override each other's. The messages are
+// registered in the global bundle of go-i18n too, for the code calling
+// go-i18n directly: a Translator that was never loaded, such as a zero
+// Translator, translates with this global bundle.
 type Translator struct {
 	// FS that contains the files
 	FS fs.FS
@@ -30,68 +109,953 @@ type Translator struct {
 	DefaultLanguage string
 	// HelperName - name of the view helper. default is "t"
 	HelperName string
+	// HelperNames - other names of the view helper, e.g. to keep the
+	// templates using another name working during a migration. "T" is
+	// reserved for the translation function of the context, which templates
+	// can call too, but without the features of the Translator
+	// (FallbackTranslations, GlobalTemplateData...).
+	HelperNames []string
 	// LanguageExtractors - a sorted list of user language extractors.
 	LanguageExtractors []LanguageExtractor
+	// WeightedLanguageExtractors - user language extractors, sorted by the weight
+	// of the languages they return. Their languages come before the ones from
+	// LanguageExtractors.
+	WeightedLanguageExtractors []WeightedLanguageExtractor
 	// LanguageExtractorOptions - a map with options to give to LanguageExtractors.
 	LanguageExtractorOptions LanguageExtractorOptions
+	// ExtractorOptionKeys - the LanguageExtractorOptions keys used by custom
+	// extractors, so that Validate knows them.
+	ExtractorOptionKeys []string
+	// FilterAvailable - only keep the user languages matching one of the
+	// AvailableLanguages (by base language).
+	FilterAvailable bool
+	// KeepDefaultLanguage - with FilterAvailable, keep DefaultLanguage as the
+	// last fallback even if it isn't available.
+	KeepDefaultLanguage bool
+	// PreferDefault - put DefaultLanguage first in the languages of the
+	// user, the other ones being fallbacks, e.g. for an admin area always
+	// shown in the language of the company.
+	PreferDefault bool
+	// CanonicalLanguages - store the languages of the user in the
+	// "languages" context value in canonical form ("fr-FR" for "fr-fr" or
+	// "fr_FR"), without the invalid or unknown tags and the duplicates, the
+	// default language included. By default, the tags are stored as the
+	// extractors found them, e.g. "fr-fr" or "english" from a cookie, only
+	// deduplicated.
+	CanonicalLanguages bool
+	// RequiredLanguages - languages for which a broken locale file makes Load
+	// fail. When set, the broken files of the other languages are logged and
+	// skipped. By default, any broken file makes Load fail.
+	RequiredLanguages []string
+	// Logger - logger used outside of requests, e.g. to report the locale
+	// files skipped by Load. default is a buffalo logger at info level.
+	Logger buffalo.Logger
+	// SilentMode - disable all the logging, outside of requests (Logger is
+	// ignored) as well as in requests, e.g. in serverless functions or in
+	// contexts without a buffalo logger.
+	SilentMode bool
+	// ConflictPolicy - which message to keep when several locale files of
+	// a language, or Merge, define the same message. default is LastWins.
+	ConflictPolicy ConflictPolicy
+	// FallbackTranslations - when a message is missing or left blank in the
+	// language of the request, use the next languages of the request (down to
+	// the default language) rather than rendering the message ID. Translators
+	// often leave blank the messages that aren't translated yet.
+	FallbackTranslations bool
+	// ReloadDebounce - in development, how long the locale files must have
+	// been left unchanged before they are reloaded, so a file being saved
+	// isn't loaded half-written. default is 200ms.
+	ReloadDebounce time.Duration
+	// LanguageCookie - attributes (Path, MaxAge, Secure, SameSite, HttpOnly...)
+	// of the cookie written by SetLanguage. Its name is the "CookieName"
+	// option, and its value the language.
+	LanguageCookie http.Cookie
+	// IncrementalReload - in development, only reload the locale files that
+	// changed rather than all of them. Handy with large bundles.
+	IncrementalReload bool
+	// LenientReload - in development, when reloading a broken locale file,
+	// e.g. saved half-written, keep serving its last good messages and log
+	// the error, rather than failing the requests until it is fixed.
+	LenientReload bool
+	// IDTransform - maps the message IDs used in the code to the IDs of the
+	// locale files, e.g. "checkout.button.pay" to "checkout_button_pay".
+	// It applies to Translate, TranslateMap and the view helper.
+	IDTransform func(string) string
+	// GlobalTemplateData - template data available to every message, such
+	// as the name of the app. The data given to a translation overrides it.
+	GlobalTemplateData map[string]interface{}
+	// TrackMissing - record the messages rendered as their ID during a
+	// request, see MissingThisRequest. Meant for development.
+	TrackMissing bool
+	// TrackUsage - record the IDs of the messages translated during the
+	// lifetime of the process, see UsedKeys. Meant for finding the orphaned
+	// messages after a test run.
+	TrackUsage bool
+	// LocalizeNumbers - print the numbers of the template data, .Count
+	// included, in the format of the language ("1,234" in English, "1 234"
+	// in French) when translating from a context. The templates can then
+	// only print these numbers, not compare or compute with them.
+	LocalizeNumbers bool
+	// BidiIsolates - wrap the strings of the template data in Unicode
+	// isolates (U+2066 and U+2069) in the right-to-left languages, when
+	// translating from a context, so that a name written from left to
+	// right doesn't garble the sentence around it.
+	BidiIsolates bool
+	// CaseInsensitiveIDs - match the message IDs regardless of their case,
+	// so "User.Name" finds the "user.name" message. The IDs are lowercased
+	// when loaded: Load again a Translator returned by New after setting it.
+	CaseInsensitiveIDs bool
+	// LazyLoad - only parse the locale files of a language when it is first
+	// used, rather than in Load. Handy with many languages, most of them
+	// seldom used. See NewLazy.
+	LazyLoad bool
+	// NoFallbackPrefixes - messages that must never be shown in another
+	// language, such as legal texts, by ID prefix ("legal."). They don't
+	// use FallbackTranslations, and are rendered empty rather than as their
+	// ID when missing, or when the response isn't in the first language of
+	// the user, e.g. in the default language for lack of locale files.
+	NoFallbackPrefixes []string
+	// Namespace - prefix of the IDs of the loaded messages, so the messages
+	// of a component ("button.submit") don't collide with the ones of the
+	// app once merged ("lib.button.submit" with the "lib" namespace). As
+	// for CaseInsensitiveIDs, Load again a Translator returned by New after
+	// setting it.
+	Namespace string
+	// Domains - top-level directories of FS holding the messages of a
+	// functional domain ("emails", "sms"), rather than shared ones. The
+	// messages of a domain are translated with TranslateDomain, and
+	// override the shared ones with the same ID there. As for Namespace,
+	// Load again a Translator returned by New after setting it.
+	Domains []string
+	// TenantSelector - returns the tenant of the request, whose overlay
+	// messages (see WithOverlay) win over the ones of the Translator.
+	TenantSelector func(c buffalo.Context) string
+	// TrimWhitespace - remove the trailing whitespace of the lines of the
+	// loaded messages, and normalize their line endings to "\n". YAML block
+	// scalars easily get invisible whitespace from the editors that would
+	// show up in the output. Set it before loading the messages, see
+	// Namespace.
+	TrimWhitespace bool
+	// RenderFilter - transform the translations rendered from a context,
+	// e.g. to collapse the double spaces left by empty placeholders, or to
+	// remove the editorial markers of the translators. It isn't applied to
+	// the IDs rendered for the missing messages.
+	RenderFilter func(string) string
+
+	mu sync.RWMutex
+	// loadMu serializes the loads of the locale files, which read the
+	// files of t.sources and replace them outside of mu
+	loadMu sync.Mutex
+	// bundle holds the messages of t for go-i18n, see messageBundle
+	bundle   *bundle.Bundle
+	messages messageIndex
+	// parsed locale files, as of the last Load
+	sources localeFiles
+	// locale files added with AddFile, by name
+	added localeFiles
+	// messages added with AddTranslation, LoadMap or Merge, which the loads
+	// keep on top of the locale files
+	extra     messageIndex
+	available []string
+	// modification times of the locale files, as of the last Load
+	files map[string]time.Time
+	// paths of the locale files left for later by LazyLoad, by language
+	pending map[string][]string
+	// translation functions by language, see tfunc
+	tfuncs map[string]i18n.TranslateFunc
+	// generation of the messages, incremented by each load: tfuncs is only
+	// valid for tfuncsGeneration
+	generation       uint64
+	tfuncsGeneration uint64
+	// messages of the tenants, see WithOverlay
+	overlays map[string]*bundle.Bundle
+	// IDs of the translated messages, see TrackUsage
+	used sync.Map
+	// parsed message templates of TranslateTo, by source
+	templates map[string]*template.Template
+}
+
+// LoadErrors is returned by Load when some locale files could not be read
+// or parsed, or conflict with ErrorOnConflict. The other files are still
+// loaded.
+type LoadErrors []error
+
+func (e LoadErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
 }
 
-// Load translations from the t.FS
+// Load translations from the t.FS. A broken file doesn't stop the walk:
+// the remaining files are loaded, and all the failures are returned
+// together as LoadErrors. The files are loaded in path order, so that the
+// messages defined by several files of the same language are resolved the
+// same way everywhere, following t.ConflictPolicy.
+//
+// A YAML or JSON locale file can include other files, relative to its
+// directory, with an "_include" directive: a top-level key of the flat
+// format, or an entry of the list format.
+//
+//	_include: [common.yaml]
+//	welcome: "Welcome!"
+//
+// The included messages get the language of the including file, which can
+// override them. Files whose name starts with an underscore, such as
+// "_common.yaml", are fragments: they are only loaded when included, and
+// the underscore can be left out of the directive.
 func (t *Translator) Load() error {
-	return fs.WalkDir(t.FS, ".", func(path string, d fs.DirEntry, err error) error {
+	return t.LoadContext(context.Background())
+}
+
+// LoadContext is like Load, but stops loading the files once ctx is done,
+// e.g. on shutdown, returning its error. The messages loaded before are
+// kept as is.
+func (t *Translator) LoadContext(ctx context.Context) error {
+	t.loadMu.Lock()
+	defer t.loadMu.Unlock()
+
+	var errs LoadErrors
+	sources := localeFiles{}
+	files := map[string]time.Time{}
+	pending := map[string][]string{}
+	t.mu.RLock()
+	previous := t.sources
+	t.mu.RUnlock()
+	err := fs.WalkDir(t.FS, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
 			return err
 		}
 
 		if d.IsDir() {
 			return nil
 		}
+		if info, err := d.Info(); err == nil {
+			files[path] = info.ModTime()
+		}
+		if isFragment(path) {
+			return nil
+		}
 
-		b, err := fs.ReadFile(t.FS, path)
-		if err != nil {
-			return fmt.Errorf("unable to read locale file %s: %v", path, err)
+		if tag := fileLanguage(path); t.LazyLoad && tag != "" {
+			pending[tag] = append(pending[tag], path)
+			return nil
+		}
+		if lf := t.loadFile(path, &errs); lf != nil {
+			sources[path] = lf
+		} else if lf := t.lastGood(previous, path); lf != nil {
+			sources[path] = lf
+		}
+		return nil
+	})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	resolved, conflicts := t.resolveConflicts(sources)
+	errs = append(errs, conflicts...)
+	t.mu.Lock()
+	t.sources = sources
+	t.files = files
+	t.pending = pending
+	t.setMessages(t.added.addTo(resolved.index()))
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// lastGood returns the messages of the locale file at path as of the
+// previous load, with LenientReload, or nil.
+func (t *Translator) lastGood(previous localeFiles, path string) *localeFile {
+	if !t.LenientReload {
+		return nil
+	}
+	return previous[path]
+}
+
+// localeFile holds the messages parsed from a locale file.
+type localeFile struct {
+	lang         *language.Language
+	translations []translation.Translation
+	descriptions map[string]string
+	// includes are the paths of the files included by the locale file
+	includes []string
+	// origins are the paths of the included files defining the messages,
+	// by ID
+	origins map[string]string
+}
+
+// sources returns the paths of the files defining the messages of the
+// locale file at path, by ID.
+func (lf *localeFile) sources(path string) map[string]string {
+	sources := make(map[string]string, len(lf.translations))
+	for _, tr := range lf.translations {
+		if origin, ok := lf.origins[tr.ID()]; ok {
+			sources[tr.ID()] = origin
+		} else {
+			sources[tr.ID()] = path
+		}
+	}
+	return sources
+}
+
+// localeFiles holds the parsed locale files, by path.
+type localeFiles map[string]*localeFile
+
+// index returns the messages of the locale files. The files are indexed in
+// path order, so a message defined by several files of the same language
+// comes from the last one.
+func (lfs localeFiles) index() messageIndex {
+	return lfs.addTo(messageIndex{})
+}
+
+// addTo adds the messages of the locale files to messages, in path order as
+// for index, and returns it.
+func (lfs localeFiles) addTo(messages messageIndex) messageIndex {
+	for _, path := range lfs.paths() {
+		lf := lfs[path]
+		// the index merges messages in place, keep the parsed ones intact
+		messages.add(lf.lang.Tag, cloneTranslations(lf.translations), lf.descriptions, lf.sources(path))
+	}
+	return messages
+}
+
+// paths returns the paths of the locale files, sorted.
+func (lfs localeFiles) paths() []string {
+	paths := make([]string, 0, len(lfs))
+	for path := range lfs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// resolveConflicts returns the locale files with the messages they
+// contribute under t.ConflictPolicy. With LastWins, the files are indexed
+// as is. Otherwise a message already defined by a previous file (in path
+// order) of the same language is dropped, and reported as an error with
+// ErrorOnConflict.
+func (t *Translator) resolveConflicts(lfs localeFiles) (localeFiles, []error) {
+	if t.ConflictPolicy == LastWins {
+		return lfs, nil
+	}
+	var errs []error
+	resolved := make(localeFiles, len(lfs))
+	// path of the file defining each message, by language
+	defined := map[string]map[string]string{}
+	for _, path := range lfs.paths() {
+		lf := lfs[path]
+		if defined[lf.lang.Tag] == nil {
+			defined[lf.lang.Tag] = map[string]string{}
+		}
+		kept := make([]translation.Translation, 0, len(lf.translations))
+		for _, tr := range lf.translations {
+			if first, ok := defined[lf.lang.Tag][tr.ID()]; ok {
+				if t.ConflictPolicy == ErrorOnConflict {
+					errs = append(errs, fmt.Errorf("i18n: message %q is defined by both %s and %s", tr.ID(), first, path))
+				}
+				continue
+			}
+			defined[lf.lang.Tag][tr.ID()] = path
+			kept = append(kept, tr)
+		}
+		resolved[path] = &localeFile{lang: lf.lang, translations: kept, descriptions: lf.descriptions, includes: lf.includes, origins: lf.origins}
+	}
+	return resolved, errs
+}
+
+// setMessages replaces the messages of t with the ones of the locale files,
+// completed with t.extra, and its go-i18n bundle with a fresh one holding
+// them: the bundle only ever adds messages, and the ones removed from a
+// locale file must be gone once it is reloaded. The messages are registered
+// in the global bundle of go-i18n too, see Translator. It must be called
+// with t.mu held, once t.pending is up to date.
+func (t *Translator) setMessages(messages messageIndex) {
+	messages = t.extra.addTo(messages)
+	t.messages = messages
+	t.bundle = messages.bundle()
+	t.templates = nil
+	messages.addTranslations(i18n.AddTranslation)
+	// without any message, register the default language anyway, so that
+	// it is available and the user languages have something to match
+	if lang := t.defaultLanguage(); lang != nil && messages.empty() && len(t.pending) == 0 {
+		t.bundle.AddTranslation(lang, cloneTranslation(placeholder))
+		i18n.AddTranslation(lang, cloneTranslation(placeholder))
+	}
+	t.available = nil
+	t.generation++
+}
+
+// placeholder registers a language without messages in a go-i18n bundle,
+// whose translation functions only match the languages having some. Its ID
+// is empty, and an empty ID is translated as is anyway.
+var placeholder, _ = translation.NewTranslation(map[string]interface{}{"id": "", "translation": ""})
+
+// messageBundle returns the go-i18n bundle holding the messages of t, or nil
+// if t was never loaded (see Translator). Each loaded Translator translates
+// with a bundle of its own, rather than the global one of go-i18n, so that
+// translators loading different files (an app and its plugins, the public
+// pages and an admin area) don't override each other's messages.
+func (t *Translator) messageBundle() *bundle.Bundle {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.bundle
+}
+
+// tfuncAndLanguage returns the translation function of t for the first of
+// langs having messages, and its language, as i18n.TfuncAndLanguage does.
+// langs must not be empty.
+func (t *Translator) tfuncAndLanguage(langs ...string) (i18n.TranslateFunc, *language.Language, error) {
+	b := t.messageBundle()
+	if b == nil {
+		return i18n.TfuncAndLanguage(langs[0], langs[1:]...)
+	}
+	T, lang, err := b.TfuncAndLanguage(langs[0], langs[1:]...)
+	return i18n.TranslateFunc(T), lang, err
+}
+
+// loadFile reads and parses the locale file at path. It returns nil if the
+// file can't be loaded, the failure being recorded in errs.
+func (t *Translator) loadFile(path string, errs *LoadErrors) *localeFile {
+	name := localeFileName(path)
+
+	b, err := readLocaleFile(t.FS, path)
+	if err != nil {
+		t.loadFailed(errs, name, fmt.Errorf("unable to read locale file %s: %v", path, err))
+		return nil
+	}
+
+	lf, err := t.parseFileIncludes(path, name, b, 0)
+	if err != nil {
+		t.loadFailed(errs, name, fmt.Errorf("unable to parse locale file %s: %v", filepath.Base(path), err))
+		return nil
+	}
+	if domain := t.fileDomain(path); domain != "" {
+		lf = lf.inDomain(domain)
+	}
+	return lf
+}
+
+// parseFile parses the content of a locale file, named as expected by
+// go-i18n (see localeFileName).
+func (t *Translator) parseFile(name string, b []byte) (*localeFile, error) {
+	lang, translations, err := parseTranslationFile(name, b)
+	if err != nil {
+		return nil, err
+	}
+	return &localeFile{
+		lang:         lang,
+		translations: t.loadedTranslations(translations),
+		descriptions: t.loadedDescriptions(parseDescriptions(name, b)),
+	}, nil
+}
+
+// AddFile loads a locale file from its name and content rather than from
+// FS, e.g. a file uploaded by the admins of the app for a new language. The
+// language is told by name, as for the files of FS: "fr-ca.yaml" or
+// "messages.fr-ca.yaml". AvailableLanguages is updated right away, without
+// reloading the other files, and the messages of name win over the ones of
+// FS. They are kept by the later loads; adding a file of the same name
+// again replaces them.
+func (t *Translator) AddFile(name string, data []byte) error {
+	b, err := decodeLocaleFile(data)
+	if err != nil {
+		return fmt.Errorf("unable to read locale file %s: %v", name, err)
+	}
+	lf, err := t.parseFile(localeFileName(name), b)
+	if err != nil {
+		return fmt.Errorf("unable to parse locale file %s: %v", filepath.Base(name), err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	added := make(localeFiles, len(t.added)+1)
+	for n, f := range t.added {
+		added[n] = f
+	}
+	added[name] = lf
+	t.added = added
+	resolved, _ := t.resolveConflicts(t.sources)
+	t.setMessages(added.addTo(resolved.index()))
+	return nil
+}
+
+// fileLanguage returns the language tag of the locale file at path, or an
+// empty string if it can't be told from its path.
+func fileLanguage(path string) string {
+	if langs := language.Parse(filepath.Base(localeFileName(path))); len(langs) == 1 {
+		return langs[0].Tag
+	}
+	return ""
+}
+
+// isPending tells whether path is one of the pending paths.
+func isPending(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// loadLanguages parses the locale files left for later by LazyLoad, for
+// langs and the languages sharing their base language ("fr-ca" and
+// "fr-fr" for "fr"), so go-i18n can fall back from one to the other.
+func (t *Translator) loadLanguages(langs ...string) error {
+	if !t.hasPending(langs) {
+		return nil
+	}
+	t.loadMu.Lock()
+	defer t.loadMu.Unlock()
+
+	t.mu.Lock()
+	paths := []string{}
+	for _, lang := range langs {
+		base := baseLanguage(lang)
+		for tag, tagPaths := range t.pending {
+			if baseLanguage(tag) == base {
+				paths = append(paths, tagPaths...)
+				delete(t.pending, tag)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	sources := make(localeFiles, len(t.sources)+len(paths))
+	for path, lf := range t.sources {
+		sources[path] = lf
+	}
+	t.mu.Unlock()
+
+	sort.Strings(paths)
+	var errs LoadErrors
+	for _, path := range paths {
+		if lf := t.loadFile(path, &errs); lf != nil {
+			sources[path] = lf
+		}
+	}
+	resolved, conflicts := t.resolveConflicts(sources)
+	errs = append(errs, conflicts...)
+
+	t.mu.Lock()
+	t.setMessages(t.added.addTo(resolved.index()))
+	t.sources = sources
+	t.mu.Unlock()
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// hasPending tells whether locale files of langs, or of the languages
+// sharing their base language, are left for later by LazyLoad.
+func (t *Translator) hasPending(langs []string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, lang := range langs {
+		base := baseLanguage(lang)
+		for tag := range t.pending {
+			if baseLanguage(tag) == base {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reloadChanged reloads only the locale files that changed since the last
+// load, and forgets the messages of the removed ones. As for Load, the
+// messages added with AddTranslation, LoadMap or Merge are kept.
+func (t *Translator) reloadChanged() error {
+	t.loadMu.Lock()
+	defer t.loadMu.Unlock()
+
+	files, _, err := t.scanFiles()
+	if err != nil {
+		return err
+	}
+
+	t.mu.RLock()
+	previous := t.sources
+	sources := make(localeFiles, len(t.sources))
+	for path, lf := range t.sources {
+		sources[path] = lf
+	}
+	loaded := t.files
+	pending := map[string][]string{}
+	for tag, paths := range t.pending {
+		for _, path := range paths {
+			if _, ok := files[path]; ok {
+				pending[tag] = append(pending[tag], path)
+			}
+		}
+	}
+	t.mu.RUnlock()
+
+	var errs LoadErrors
+	for path := range loaded {
+		if _, ok := files[path]; !ok {
+			delete(sources, path)
+		}
+	}
+	changed := map[string]bool{}
+	for path, modTime := range files {
+		if prev, ok := loaded[path]; !ok || !prev.Equal(modTime) {
+			changed[path] = true
+		}
+	}
+	for path := range files {
+		if lf, ok := sources[path]; ok && lf.includesChanged(changed) {
+			changed[path] = true
+		}
+		if !changed[path] || isFragment(path) {
+			continue
+		}
+		delete(sources, path)
+		if tag := fileLanguage(path); t.LazyLoad && tag != "" {
+			// parsed again on the next use of its language
+			if !isPending(pending[tag], path) {
+				pending[tag] = append(pending[tag], path)
+			}
+			continue
+		}
+		if lf := t.loadFile(path, &errs); lf != nil {
+			sources[path] = lf
+		} else if lf := t.lastGood(previous, path); lf != nil {
+			sources[path] = lf
+		}
+	}
+	resolved, conflicts := t.resolveConflicts(sources)
+	errs = append(errs, conflicts...)
+
+	t.mu.Lock()
+	t.sources = sources
+	t.files = files
+	t.pending = pending
+	t.setMessages(t.added.addTo(resolved.index()))
+	t.mu.Unlock()
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Watch reloads the locale files each time trigger fires, e.g. after a
+// publication of a CMS, rather than polling FS in development. It reloads
+// the changed files only with IncrementalReload. Watch blocks until ctx is
+// done, returning its error, or until trigger is closed, returning nil. The
+// reload failures are reported to t.Logger.
+//
+//	go transl.Watch(ctx, published)
+func (t *Translator) Watch(ctx context.Context, trigger <-chan struct{}) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-trigger:
+			if !ok {
+				return nil
+			}
+			if err := t.reload(ctx); err != nil {
+				t.logger().Error(err)
+			}
+		}
+	}
+}
+
+// ReloadIfChanged reloads the locale files if they changed since the last
+// load, as the middleware does in development, and tells whether it did.
+// It is safe to call at any time, e.g. from an admin endpoint, to pick up
+// new translations in production without thrashing.
+func (t *Translator) ReloadIfChanged() (bool, error) {
+	if !t.needsReload() {
+		return false, nil
+	}
+	return true, t.reload(context.Background())
+}
+
+// reload reloads the locale files: all of them, or the changed ones only
+// with IncrementalReload. A full reload stops once ctx is done.
+func (t *Translator) reload(ctx context.Context) error {
+	if t.IncrementalReload {
+		return t.reloadChanged()
+	}
+	return t.LoadContext(ctx)
+}
+
+// needsReload tells whether the locale files changed since the last Load.
+// Changes are only reported once the files have been left unchanged for
+// t.ReloadDebounce.
+func (t *Translator) needsReload() bool {
+	files, latest, err := t.scanFiles()
+	if err != nil {
+		return false
+	}
+
+	t.mu.RLock()
+	changed := len(files) != len(t.files)
+	for path, modTime := range files {
+		if loaded, ok := t.files[path]; !ok || !loaded.Equal(modTime) {
+			changed = true
 		}
+	}
+	t.mu.RUnlock()
 
-		base := filepath.Base(path)
-		dir := filepath.Dir(path)
+	return changed && time.Since(latest) >= t.ReloadDebounce
+}
 
-		// Add a prefix to the loaded string, to avoid collision with an ISO lang code
-		err = i18n.ParseTranslationFileBytes(fmt.Sprintf("%sbuff%s", dir, base), b)
+// scanFiles returns the modification times of the files of t.FS, and the
+// latest of them.
+func (t *Translator) scanFiles() (map[string]time.Time, time.Time, error) {
+	files := map[string]time.Time{}
+	var latest time.Time
+	err := fs.WalkDir(t.FS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
 		if err != nil {
-			return fmt.Errorf("unable to parse locale file %s: %v", base, err)
+			return nil
+		}
+		files[path] = info.ModTime()
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
 		}
 		return nil
 	})
+	return files, latest, err
+}
+
+// localeFileName returns the name used to parse the locale file at path,
+// from which go-i18n reads the language of the file.
+func localeFileName(path string) string {
+	base := filepath.Base(path)
+	dir := filepath.Dir(path)
+
+	// Add a prefix to the loaded string, to avoid collision with an ISO lang code
+	name := fmt.Sprintf("%sbuff%s", dir, base)
+	if lang := dirLanguage(dir); lang != "" {
+		// directory-per-language layout: "en/messages.yaml", unless the
+		// file name holds its own language
+		name = "buff" + base
+		if len(language.Parse(name)) == 0 {
+			name = lang + filepath.Ext(base)
+		}
+	}
+	return name
+}
+
+// loadFailed records the failure to load the locale file parsed as name.
+// When RequiredLanguages is set, the failures for the other languages are
+// only logged.
+func (t *Translator) loadFailed(errs *LoadErrors, name string, err error) {
+	if len(t.RequiredLanguages) == 0 || t.isRequired(name) {
+		*errs = append(*errs, err)
+		return
+	}
+	t.logger().Warnf("i18n: skipping optional locale file: %v", err)
+}
+
+// isRequired tells whether the locale file parsed as name holds a language
+// of RequiredLanguages. Files without a clear language are required.
+func (t *Translator) isRequired(name string) bool {
+	langs := language.Parse(filepath.Base(name))
+	if len(langs) != 1 {
+		return true
+	}
+	for _, required := range t.RequiredLanguages {
+		required = language.NormalizeTag(required)
+		if langs[0].Tag == required || strings.HasPrefix(langs[0].Tag, required+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// dirLanguage returns the language tag named by the last element of dir,
+// or an empty string if it isn't a valid language tag.
+func dirLanguage(dir string) string {
+	base := filepath.Base(dir)
+	if _, err := xlanguage.Parse(base); err != nil {
+		return ""
+	}
+	if langs := language.Parse(base); len(langs) == 1 {
+		return langs[0].Tag
+	}
+	return ""
 }
 
 // AddTranslation directly, without using a file. This is useful if you wish to load translations
-// from a database, instead of disk.
+// from a database, instead of disk. It is safe to call while translating:
+// the messages are visible right away, AvailableLanguages and the
+// translation functions being refreshed. They are kept by the later loads,
+// on top of the messages of the locale files.
 func (t *Translator) AddTranslation(lang *language.Language, translations ...translation.Translation) {
-	i18n.AddTranslation(lang, translations...)
+	translations = t.loadedTranslations(translations)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// under the lock, so that the go-i18n bundles and the messages of t
+	// change together
+	if t.bundle != nil {
+		t.bundle.AddTranslation(lang, cloneTranslations(translations)...)
+	}
+	i18n.AddTranslation(lang, cloneTranslations(translations)...)
+	if t.messages == nil {
+		t.messages = messageIndex{}
+	}
+	if t.extra == nil {
+		t.extra = messageIndex{}
+	}
+	t.messages.add(lang.Tag, translations, nil, nil)
+	t.extra.add(lang.Tag, cloneTranslations(translations), nil, nil)
+	t.available = nil
+	t.generation++
+}
+
+// LoadMap loads translations from a map of language tags to message IDs and
+// values, such as a map generated from the locale files with go:generate.
+// As for Load, a broken language or message doesn't stop the loading of the
+// other ones, and the failures are returned as LoadErrors.
+func (t *Translator) LoadMap(m map[string]map[string]string) error {
+	tags := make([]string, 0, len(m))
+	for tag := range m {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	var errs LoadErrors
+	for _, tag := range tags {
+		langs := language.Parse(tag)
+		if len(langs) != 1 {
+			errs = append(errs, fmt.Errorf("invalid language %q", tag))
+			continue
+		}
+
+		translations := make([]translation.Translation, 0, len(m[tag]))
+		for id, value := range m[tag] {
+			tr, err := translation.NewTranslation(map[string]interface{}{
+				"id":          id,
+				"translation": value,
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("unable to parse message %s for %s: %v", id, tag, err))
+				continue
+			}
+			translations = append(translations, tr)
+		}
+		t.AddTranslation(langs[0], translations...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
 // New Translator. Requires a fs.FS that points to the location
 // of the translation files, as well as a default language. This will
-// also call t.Load() and load the translations from disk.
+// also call t.Load() and load the translations from disk, registering them
+// in the global bundle of go-i18n too (see Translator).
 func New(fsys fs.FS, language string) (*Translator, error) {
-	t := &Translator{
+	t := newTranslator(fsys, language)
+	return t, t.Load()
+}
+
+// newTranslator returns a Translator with the default settings, without
+// loading it.
+func newTranslator(fsys fs.FS, language string) *Translator {
+	return &Translator{
 		FS:              fsys,
 		DefaultLanguage: language,
 		HelperName:      "t",
 		LanguageExtractorOptions: LanguageExtractorOptions{
-			"CookieName":    "lang",
-			"SessionName":   "lang",
-			"URLPrefixName": "lang",
+			"CookieName":     "lang",
+			"SessionName":    "lang",
+			"URLPrefixName":  "lang",
+			"RouteParamName": "locale",
 		},
 		LanguageExtractors: []LanguageExtractor{
 			CookieLanguageExtractor,
 			SessionLanguageExtractor,
 			HeaderLanguageExtractor,
 		},
+		Logger:         logger.New(logger.InfoLevel),
+		ReloadDebounce: 200 * time.Millisecond,
+		LanguageCookie: http.Cookie{
+			Path:     "/",
+			MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+			SameSite: http.SameSiteLaxMode,
+		},
+	}
+}
+
+// NewEmbed is like New, for locale files embedded with go:embed. An
+// embed.FS keeps the path of the embedded files, so that
+//
+//	//go:embed locales
+//	var localesFS embed.FS
+//
+// holds "locales/all.en-us.yaml" rather than "all.en-us.yaml". NewEmbed
+// loads the files under root (here, "locales"), and fails if root isn't an
+// embedded directory.
+func NewEmbed(fsys embed.FS, root, language string) (*Translator, error) {
+	info, err := fs.Stat(fsys, root)
+	if err != nil {
+		return nil, fmt.Errorf("i18n: invalid embed root %q: %v", root, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("i18n: invalid embed root %q: not a directory", root)
+	}
+	sub, err := fs.Sub(fsys, root)
+	if err != nil {
+		return nil, fmt.Errorf("i18n: invalid embed root %q: %v", root, err)
 	}
+	return New(sub, language)
+}
+
+// NewLazy is like New, with the LazyLoad option: the locale files of a
+// language are only parsed when the language is first used.
+func NewLazy(fsys fs.FS, language string) (*Translator, error) {
+	t := newTranslator(fsys, language)
+	t.LazyLoad = true
 	return t, t.Load()
 }
 
+// NewStrict is like New, but requires the default language to be a
+// well-formed BCP 47 tag, such as "en-US". New accepts any value: a typo
+// like "english" or "en_US" isn't reported, it only makes the language
+// matching behave oddly later on.
+func NewStrict(fsys fs.FS, language string) (*Translator, error) {
+	if err := validateTag(language); err != nil {
+		return nil, err
+	}
+	return New(fsys, language)
+}
+
+// validateTag checks that tag is a well-formed BCP 47 language tag, written
+// as such.
+func validateTag(tag string) error {
+	parsed, err := xlanguage.Raw.Parse(tag)
+	if err != nil {
+		return fmt.Errorf("i18n: invalid language tag %q: %v", tag, err)
+	}
+	if parsed == xlanguage.Und || !strings.EqualFold(parsed.String(), tag) {
+		return fmt.Errorf("i18n: invalid language tag %q", tag)
+	}
+	return nil
+}
+
 // Middleware for loading the translations for the language(s)
 // selected. By default languages are loaded in the following order:
 //
@@ -100,40 +1064,121 @@ func New(fsys fs.FS, language string) (*Translator, error) {
 // Header - "Accept-Language"
 // Default - "en-US"
 //
+// The language of the response is declared in the Content-Language header.
 // These values can be changed on the Translator itself. In development
-// model the translation files will be reloaded on each request.
+// mode the translation files will be reloaded when they change.
+//
+// The handlers building strings in Go can translate without a reference to
+// the Translator, with the "translate" context value:
+//
+//	tr := c.Value("translate").(func(string, ...interface{}) (string, error))
+//	s, err := tr("greeting")
+//
+// Its error tells that the message has no translation.
+//
+// The "languages" context value holds the languages of the user, from the
+// most to the least preferred, the default language last: as found by the
+// extractors, or in canonical form with CanonicalLanguages.
+//
+// The "languageExactMatch" context value tells whether the response is in
+// the preferred language of the user, rather than in a fallback one, e.g.
+// to show a "help us translate" banner when it is false.
+//
+// Several translators can be used in the same app, e.g. one for the public
+// pages and one for an admin area, each one with its own locale files:
+//
+//	app.Use(public.Middleware())
+//	admin.HelperName = "at"
+//	app.Use(admin.Middleware())
+//
+// Their HelperName must differ: each one sets its own helper, translating
+// its own messages in the languages it negotiated. The context values
+// ("languages", "T", "translate"...) and the other helpers ("translateEach",
+// "hreflang"...) are the ones of the first translator.
 func (t *Translator) Middleware() buffalo.MiddlewareFunc {
+	return t.middleware(true)
+}
+
+// APIMiddleware is like Middleware, but it doesn't set up the view helpers.
+// It only sets the "languages", "T" and "translate" context values, for APIs
+// translating from the handlers rather than from templates.
+func (t *Translator) APIMiddleware() buffalo.MiddlewareFunc {
+	return t.middleware(false)
+}
+
+func (t *Translator) middleware(viewHelpers bool) buffalo.MiddlewareFunc {
+	// the helpers that don't depend on the request are only allocated once
+	hreflang := t.Hreflang
 	return func(next buffalo.Handler) buffalo.Handler {
 		return func(c buffalo.Context) error {
 
-			// in development reload the translations
+			// in development reload the translations when they change
 			if c.Value("env").(string) == "development" {
-				err := t.Load()
+				if _, err := t.ReloadIfChanged(); err != nil {
+					if !t.LenientReload {
+						return err
+					}
+					t.contextLogger(c).Error(err)
+				}
+			}
+
+			// set the languages and the translator of t, unless this
+			// middleware already did
+			if t.state(c) == nil {
+				langs := t.negotiateLanguages(c)
+				if err := t.loadLanguages(langs...); err != nil {
+					t.contextLogger(c).Error(err)
+				}
+				T, lang, err := t.tfuncAndLanguage(langs...)
 				if err != nil {
-					return err
+					t.contextLogger(c).Warn(err)
+					t.contextLogger(c).Warn("Your locale files are probably empty or missing")
+				}
+				t.setState(c, &requestState{languages: langs, T: T, lang: lang})
+				// the first translator sets the translator of the app
+				if c.Value("T") == nil {
+					c.Set("T", T)
+				}
+				if c.Value(languageKey) == nil {
+					c.Set(languageKey, lang)
+					c.Set("languageExactMatch", exactMatch(langs[0], lang))
 				}
 			}
+			setContentLanguage(c, contextLanguage(c))
 
-			// set languages in context, if not set yet
-			if langs := c.Value("languages"); langs == nil {
-				c.Set("languages", t.extractLanguage(c))
+			// set the translate function for the handlers
+			if c.Value("translate") == nil {
+				c.Set("translate", func(translationID string, args ...interface{}) (string, error) {
+					return t.translateChecked(c, translationID, args...)
+				})
 			}
 
-			// set translator
-			if T := c.Value("T"); T == nil {
-				langs := c.Value("languages").([]string)
-				T, err := i18n.Tfunc(langs[0], langs[1:]...)
-				if err != nil {
-					c.Logger().Warn(err)
-					c.Logger().Warn("Your locale files are probably empty or missing")
-				}
-				c.Set("T", T)
+			if !viewHelpers {
+				return next(c)
 			}
 
-			// set up the helper function for the views:
-			c.Set(t.HelperName, func(s string, i ...interface{}) string {
+			// set up the helper functions for the views:
+			helper := func(s string, i ...interface{}) string {
 				return t.Translate(c, s, i...)
+			}
+			c.Set(t.HelperName, helper)
+			for _, name := range t.HelperNames {
+				if name != "T" {
+					c.Set(name, helper)
+				}
+			}
+			if c.Value("translateEach") != nil {
+				// another translator set up the shared helpers
+				return next(c)
+			}
+			c.Set("translateEach", func(ids []string) ([]string, error) {
+				return t.TranslateEach(c, ids)
 			})
+			// tlang translates in another language than the one of the
+			// request, e.g. a quote in its original language
+			c.Set("tlang", t.TranslateWithLang)
+			c.Set("hreflang", hreflang)
+			c.Set("languageName", LanguageName)
 			return next(c)
 		}
 	}
@@ -159,25 +1204,770 @@ func (t *Translator) Middleware() buffalo.MiddlewareFunc {
 // Count field must be an integer type (int, int8, int16, int32, int64)
 // or a float formatted as a string (e.g. "123.45").
 func (t *Translator) Translate(c buffalo.Context, translationID string, args ...interface{}) string {
-	T := c.Value("T").(i18n.TranslateFunc)
-	return T(translationID, args...)
-}
-
-// TranslateWithLang returns the translation of the string identified by translationID, for the given language.
-// See Translate for further details.
-func (t *Translator) TranslateWithLang(lang, translationID string, args ...interface{}) (string, error) {
-	T, err := i18n.Tfunc(lang)
+	T, err := t.contextTfunc(c)
 	if err != nil {
-		return "", err
+		panic(err)
 	}
-	return T(translationID, args...), nil
+	return t.translate(c, T, translationID, args...)
 }
 
-// AvailableLanguages gets the list of languages provided by the app.
+// TranslateMap returns the translations of the strings identified by
+// translationIDs, by ID. It is handy to translate a set of labels at once,
+// e.g. for a form rendered on the client side. See Translate for further
+// details.
+func (t *Translator) TranslateMap(c buffalo.Context, translationIDs []string) (map[string]string, error) {
+	T, err := t.contextTfunc(c)
+	if err != nil {
+		return nil, err
+	}
+	translations := make(map[string]string, len(translationIDs))
+	for _, id := range translationIDs {
+		translations[id] = t.translate(c, T, id)
+	}
+	return translations, nil
+}
+
+// TranslateEach returns the translations of the strings identified by
+// translationIDs, in the same order, e.g. to render the options of a select.
+// It is available as the "translateEach" view helper. See Translate for
+// further details.
+func (t *Translator) TranslateEach(c buffalo.Context, translationIDs []string) ([]string, error) {
+	T, err := t.contextTfunc(c)
+	if err != nil {
+		return nil, err
+	}
+	translations := make([]string, 0, len(translationIDs))
+	for _, id := range translationIDs {
+		translations = append(translations, t.translate(c, T, id))
+	}
+	return translations, nil
+}
+
+// contextTfunc returns the translation function set by the middleware of t
+// in the context, or else its "T" translation function.
+func (t *Translator) contextTfunc(c buffalo.Context) (i18n.TranslateFunc, error) {
+	if s := t.state(c); s != nil && s.T != nil {
+		return s.T, nil
+	}
+	T, ok := c.Value("T").(i18n.TranslateFunc)
+	if !ok || T == nil {
+		return nil, fmt.Errorf("i18n: no translation function in context, is the middleware used?")
+	}
+	return T, nil
+}
+
+// contextLanguage returns the language of the translation function of
+// contextTfunc, if any.
+func (t *Translator) contextLanguage(c buffalo.Context) *language.Language {
+	if s := t.state(c); s != nil {
+		return s.lang
+	}
+	return contextLanguage(c)
+}
+
+// requestLanguages returns the languages of the user, as negotiated by the
+// middleware of t, or else the "languages" context value.
+func (t *Translator) requestLanguages(c buffalo.Context) []string {
+	if s := t.state(c); s != nil {
+		return s.languages
+	}
+	langs, _ := c.Value("languages").([]string)
+	return langs
+}
+
+// statesKey is the context key of the requestState of the translators.
+const statesKey = "i18n.translators"
+
+// requestState holds the languages and the translator set by the middleware
+// of a Translator in a request. Each Translator of an app has its own, as
+// they don't share their messages, while the "languages" and "T" context
+// values are the ones of the first Translator.
+type requestState struct {
+	translator *Translator
+	languages  []string
+	T          i18n.TranslateFunc
+	lang       *language.Language
+	// next is the state of another Translator, set earlier
+	next *requestState
+}
+
+// state returns the requestState of t in the request of c, or nil if the
+// middleware of t wasn't used.
+func (t *Translator) state(c buffalo.Context) *requestState {
+	s, _ := c.Value(statesKey).(*requestState)
+	for ; s != nil; s = s.next {
+		if s.translator == t {
+			return s
+		}
+	}
+	return nil
+}
+
+// setState sets the requestState of t in the request of c, replacing the
+// previous one, if any.
+func (t *Translator) setState(c buffalo.Context, s *requestState) {
+	s.translator = t
+	s.next, _ = c.Value(statesKey).(*requestState)
+	c.Set(statesKey, s)
+}
+
+// negotiateLanguages returns the languages of the user for t: the
+// "languages" context value when set by another middleware than a
+// Translator, the languages found by the extractors of t otherwise. The
+// first Translator sets them as the "languages" context value, along with
+// their "languageSources".
+func (t *Translator) negotiateLanguages(c buffalo.Context) []string {
+	preset := c.Value("languages")
+	if preset != nil && c.Value("languageSources") == nil {
+		if langs, ok := preset.([]string); ok && len(langs) > 0 {
+			return langs
+		}
+		// "languages" was set to something else by another middleware
+		return t.extractLanguage(c)
+	}
+	sources := t.extractLanguageSources(c)
+	langs := t.contextLanguages(languageTags(sources))
+	if preset == nil {
+		c.Set("languageSources", sources)
+		c.Set("languages", langs)
+	}
+	return langs
+}
+
+// translate is the common translation path for a context: it runs T, then
+// applies the Translator options to the result.
+func (t *Translator) translate(c buffalo.Context, T i18n.TranslateFunc, translationID string, args ...interface{}) string {
+	translationID = t.messageID(translationID)
+	args = t.withGlobalData(dataCount(args))
+	t.markUsed(translationID)
+	args = t.withNested(t.contextLanguage(c), translationID, args)
+	if s, ok := t.overlayTranslate(c, translationID, args...); ok {
+		return t.filter(s)
+	}
+	noFallback := t.noFallback(translationID)
+	if noFallback && !t.inPreferredLanguage(c) {
+		// T is bound to another language, e.g. the default one when there
+		// is no locale file for the user's
+		return ""
+	}
+	if t.LocalizeNumbers || t.BidiIsolates {
+		T = t.localizedTfunc(c, T)
+	}
+	s := T(translationID, args...)
+	if s == translationID && t.FallbackTranslations && !noFallback {
+		s = t.fallback(c, translationID, args...)
+	}
+	if s == translationID && t.TrackMissing {
+		addMissing(c, translationID)
+	}
+	if s == translationID && noFallback {
+		return ""
+	}
+	if s == translationID {
+		return s
+	}
+	return t.filter(s)
+}
+
+// filter applies the RenderFilter, if any, to a translation.
+func (t *Translator) filter(s string) string {
+	if t.RenderFilter == nil {
+		return s
+	}
+	return t.RenderFilter(s)
+}
+
+// TranslateTo writes the translation of the string identified by
+// translationID to w, e.g. the buffer of a document being rendered, rather
+// than returning it: the message template is executed right into w, without
+// building the translation as a string. As with Translate, a missing
+// message is written as its ID. The error is the one of w or of the message
+// template, or tells that the middleware wasn't used.
+func (t *Translator) TranslateTo(w io.Writer, c buffalo.Context, translationID string, args ...interface{}) error {
+	T, err := t.contextTfunc(c)
+	if err != nil {
+		return err
+	}
+	if ok, err := t.executeTo(w, c, translationID, args); ok {
+		return err
+	}
+	// the options rewriting the translation need it as a string
+	_, err = io.WriteString(w, t.translate(c, T, translationID, args...))
+	return err
+}
+
+// executeTo executes the template of the message identified by
+// translationID into w, as go-i18n does for Translate. It returns false,
+// without writing anything, when the message needs the other steps of
+// Translate: overlays, fallback, filters...
+func (t *Translator) executeTo(w io.Writer, c buffalo.Context, translationID string, args []interface{}) (bool, error) {
+	if t.RenderFilter != nil || t.TenantSelector != nil || t.LocalizeNumbers || t.BidiIsolates {
+		return false, nil
+	}
+	translationID = t.messageID(translationID)
+	lang := t.contextLanguage(c)
+	if lang == nil || t.noFallback(translationID) {
+		return false, nil
+	}
+	t.mu.RLock()
+	m := t.messages[lang.Tag][translationID]
+	t.mu.RUnlock()
+	if m == nil {
+		return false, nil
+	}
+
+	args = t.withNested(lang, translationID, t.withGlobalData(dataCount(args)))
+	count, data := splitArgs(args)
+	if count != nil {
+		merged := map[string]interface{}{}
+		for k, v := range toMap(data) {
+			merged[k] = v
+		}
+		merged["Count"] = count
+		data = merged
+	} else if n, ok := toMap(data)["Count"]; ok {
+		count = n
+	}
+	pc, _ := lang.Plural(count)
+	tmpl := m.translation.Template(pc)
+	if tmpl == nil || tmpl.String() == "" {
+		return false, nil
+	}
+
+	t.markUsed(translationID)
+	src := tmpl.String()
+	if !strings.Contains(src, "{{") {
+		_, err := io.WriteString(w, src)
+		return true, err
+	}
+	parsed, err := t.parsedTemplate(src)
+	if err != nil {
+		return true, err
+	}
+	return true, parsed.Execute(w, data)
+}
+
+// parsedTemplate returns the text/template of a message template, parsed
+// once.
+func (t *Translator) parsedTemplate(src string) (*template.Template, error) {
+	t.mu.RLock()
+	parsed, ok := t.templates[src]
+	t.mu.RUnlock()
+	if ok {
+		return parsed, nil
+	}
+	parsed, err := template.New(src).Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	if t.templates == nil {
+		t.templates = map[string]*template.Template{}
+	}
+	t.templates[src] = parsed
+	t.mu.Unlock()
+	return parsed, nil
+}
+
+// TranslateEnum returns the translation of a value of an enum-like type,
+// identified by prefix and the string of the value: "order.status.shipped"
+// for the prefix "order.status" and an OrderStatus printed as "shipped".
+// Unlike Translate, it returns an error when the message is missing, as a
+// new value of the enum is easily forgotten in the locale files. A nil
+// value, e.g. an unset pointer, is an error too.
+func (t *Translator) TranslateEnum(c buffalo.Context, prefix string, value fmt.Stringer) (string, error) {
+	if v := reflect.ValueOf(value); !v.IsValid() || v.Kind() == reflect.Ptr && v.IsNil() {
+		return "", fmt.Errorf("i18n: no %s value to translate", prefix)
+	}
+	return t.translateChecked(c, prefix+"."+value.String())
+}
+
+// translateChecked is like Translate, but it returns an error when the
+// message has no translation, or when the middleware of t wasn't used
+// rather than panicking.
+func (t *Translator) translateChecked(c buffalo.Context, translationID string, args ...interface{}) (string, error) {
+	T, err := t.contextTfunc(c)
+	if err != nil {
+		return translationID, err
+	}
+	s := t.translate(c, T, translationID, args...)
+	if id := t.messageID(translationID); s == id || s == "" && t.noFallback(id) {
+		return s, fmt.Errorf("i18n: no translation found for %q", translationID)
+	}
+	return s, nil
+}
+
+// inPreferredLanguage tells whether the translations of c are in the first
+// language of the user, see NoFallbackPrefixes.
+func (t *Translator) inPreferredLanguage(c buffalo.Context) bool {
+	langs := t.requestLanguages(c)
+	return len(langs) > 0 && exactMatch(langs[0], t.contextLanguage(c))
+}
+
+// noFallback tells whether translationID matches one of NoFallbackPrefixes.
+func (t *Translator) noFallback(translationID string) bool {
+	for _, prefix := range t.NoFallbackPrefixes {
+		if strings.HasPrefix(translationID, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// missingKey is the context key of the messages missing in a request.
+const missingKey = "i18n.missing"
+
+// addMissing records that translationID is missing in the request of c.
+func addMissing(c buffalo.Context, translationID string) {
+	missing := MissingThisRequest(c)
+	for _, id := range missing {
+		if id == translationID {
+			return
+		}
+	}
+	c.Set(missingKey, append(missing, translationID))
+}
+
+// MissingThisRequest returns the IDs of the messages that had no translation
+// so far in the request of c, in order of appearance. The messages are only
+// recorded when the TrackMissing option is set, e.g. for a dev toolbar to
+// show the untranslated strings of a page.
+func MissingThisRequest(c buffalo.Context) []string {
+	missing, _ := c.Value(missingKey).([]string)
+	return missing
+}
+
+// messageID returns the ID of the message looked up by translate for
+// translationID.
+func (t *Translator) messageID(translationID string) string {
+	if t.IDTransform != nil {
+		translationID = t.IDTransform(translationID)
+	}
+	return t.canonicalID(translationID)
+}
+
+// TranslateDefault is like Translate, but renders the defaultOther template
+// rather than translationID when the message has no translation. A message
+// can be written inline this way, until translators add it to the locale
+// files.
+func (t *Translator) TranslateDefault(c buffalo.Context, translationID, defaultOther string, args ...interface{}) string {
+	if s := t.Translate(c, translationID, args...); s != t.messageID(translationID) {
+		return s
+	}
+	count, data := splitArgs(args)
+	s, err := t.localize(nil, &LocalizeConfig{
+		MessageID:      translationID,
+		TemplateData:   data,
+		PluralCount:    count,
+		DefaultMessage: defaultOther,
+	})
+	if err != nil {
+		return translationID
+	}
+	return s
+}
+
+// fallback translates translationID with the first of the context languages
+// that has a translation for it.
+func (t *Translator) fallback(c buffalo.Context, translationID string, args ...interface{}) string {
+	for _, lang := range t.requestLanguages(c) {
+		T, l, err := t.tfuncAndLanguage(lang)
+		if err != nil {
+			continue
+		}
+		if s := T(translationID, t.withNested(l, translationID, args)...); s != translationID {
+			return s
+		}
+	}
+	return translationID
+}
+
+// withGlobalData merges t.GlobalTemplateData beneath the template data of
+// the translation arguments: an optional count, then the data.
+func (t *Translator) withGlobalData(args []interface{}) []interface{} {
+	if len(t.GlobalTemplateData) == 0 {
+		return args
+	}
+	count, data := splitArgs(args)
+	merged := t.templateData(data)
+	if count != nil {
+		return []interface{}{count, merged}
+	}
+	return []interface{}{merged}
+}
+
+// templateData returns t.GlobalTemplateData, overridden by data.
+func (t *Translator) templateData(data interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(t.GlobalTemplateData))
+	for k, v := range t.GlobalTemplateData {
+		merged[k] = v
+	}
+	for k, v := range toMap(data) {
+		merged[k] = v
+	}
+	return merged
+}
+
+// splitArgs returns the plural count and the template data of the
+// translation arguments, as go-i18n reads them.
+func splitArgs(args []interface{}) (count, data interface{}) {
+	if len(args) > 0 && isCount(args[0]) {
+		count, args = args[0], args[1:]
+	}
+	if len(args) > 0 {
+		data = args[0]
+	}
+	return count, data
+}
+
+// dataCount returns the translation arguments with the Count of the
+// template data (a struct or a map) as the plural count, when there is no
+// count argument. go-i18n reads the Count of the data itself, but only
+// when it is an int or a string: dataCount converts the other numbers, see
+// pluralCount.
+func dataCount(args []interface{}) []interface{} {
+	if len(args) != 1 || isCount(args[0]) {
+		return args
+	}
+	count, ok := pluralCount(toMap(args[0])["Count"])
+	if !ok {
+		return args
+	}
+	return []interface{}{count, args[0]}
+}
+
+// pluralCount converts a number to a plural count go-i18n accepts: an int,
+// or a string for the floats, so that 1.5 selects the form of "1.5".
+func pluralCount(n interface{}) (interface{}, bool) {
+	v := reflect.ValueOf(n)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return nil, false
+		}
+		return int64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), true
+	case reflect.String:
+		return v.String(), true
+	}
+	return nil, false
+}
+
+// isCount tells whether go-i18n takes arg as the plural count of a message
+// rather than as its template data.
+func isCount(arg interface{}) bool {
+	switch arg.(type) {
+	case int, int8, int16, int32, int64, string:
+		return true
+	}
+	return false
+}
+
+// Translatef returns the translation of the string identified by translationID,
+// formatted with fmt.Sprintf and the positional args. It is meant for messages
+// using fmt verbs ("Welcome %s") rather than template data ("Welcome {{.Name}}").
+// Plural messages use their "other" form.
+func (t *Translator) Translatef(c buffalo.Context, translationID string, args ...interface{}) (string, error) {
+	lang := t.contextLanguage(c)
+	if lang == nil {
+		return translationID, fmt.Errorf("i18n: no language set in context")
+	}
+	tr := t.lookup(lang, translationID)
+	if tr == nil {
+		return translationID, fmt.Errorf("i18n: no translation found for %q in %s", translationID, lang)
+	}
+	tmpl := tr.Template(language.Other)
+	if tmpl == nil {
+		return translationID, fmt.Errorf("i18n: no translation found for %q in %s", translationID, lang)
+	}
+	return fmt.Sprintf(tmpl.String(), args...), nil
+}
+
+// TranslateWithLang returns the translation of the string identified by translationID, for the given language.
+// See Translate for further details.
+func (t *Translator) TranslateWithLang(lang, translationID string, args ...interface{}) (string, error) {
+	T, err := t.tfunc(lang)
+	if err != nil {
+		return "", err
+	}
+	translationID = t.canonicalID(translationID)
+	t.markUsed(translationID)
+	return T(translationID, t.withGlobalData(args)...), nil
+}
+
+// TranslateDefaultLanguage returns the translation of the string identified
+// by translationID in DefaultLanguage, for the code without a request to
+// take the language from (init, CLI tools...). It returns an error if the
+// message has no translation. See Translate for further details.
+func (t *Translator) TranslateDefaultLanguage(translationID string, args ...interface{}) (string, error) {
+	s, err := t.TranslateWithLang(t.DefaultLanguage, translationID, args...)
+	if err != nil {
+		return s, err
+	}
+	if s == t.canonicalID(translationID) {
+		return s, fmt.Errorf("i18n: no translation found for %q in %s", translationID, t.DefaultLanguage)
+	}
+	return s, nil
+}
+
+// TranslateAll returns the translation of the string identified by translationID
+// for each available language, by language. It is useful to generate content in
+// every language at once, such as a localized sitemap or a broadcast message.
+// See Translate for further details.
+func (t *Translator) TranslateAll(translationID string, args ...interface{}) map[string]string {
+	translationID = t.canonicalID(translationID)
+	args = t.withGlobalData(args)
+	t.markUsed(translationID)
+	translations := map[string]string{}
+	for _, lang := range t.AvailableLanguages() {
+		T, err := t.tfunc(lang)
+		if err != nil {
+			continue
+		}
+		translations[lang] = T(translationID, args...)
+	}
+	return translations
+}
+
+// tfunc returns the translation function for a single language. The
+// functions are cached until the messages change (see generation), so a
+// language left for later by LazyLoad, or reloaded, is loaded again.
+func (t *Translator) tfunc(lang string) (i18n.TranslateFunc, error) {
+	t.mu.RLock()
+	T, ok := t.tfuncs[lang]
+	fresh := t.tfuncsGeneration == t.generation
+	t.mu.RUnlock()
+	if ok && fresh {
+		return T, nil
+	}
+
+	if err := t.loadLanguages(lang); err != nil {
+		t.logger().Error(err)
+	}
+	T, _, err := t.tfuncAndLanguage(lang)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	if t.tfuncs == nil || t.tfuncsGeneration != t.generation {
+		t.tfuncs = map[string]i18n.TranslateFunc{}
+		t.tfuncsGeneration = t.generation
+	}
+	t.tfuncs[lang] = T
+	t.mu.Unlock()
+	return T, nil
+}
+
+// AvailableLanguages gets the list of languages provided by the app,
+// including the ones not loaded yet with LazyLoad. The list is cached until
+// the next Load or AddTranslation.
 func (t *Translator) AvailableLanguages() []string {
-	lt := i18n.LanguageTags()
-	sort.Strings(lt)
-	return lt
+	t.mu.RLock()
+	lt := t.available
+	generation := t.generation
+	t.mu.RUnlock()
+
+	if lt == nil {
+		if b := t.messageBundle(); b != nil {
+			lt = b.LanguageTags()
+		} else {
+			lt = i18n.LanguageTags()
+		}
+		// add the languages left for later by LazyLoad
+		t.mu.RLock()
+		for tag := range t.pending {
+			found := false
+			for _, l := range lt {
+				found = found || l == tag
+			}
+			if !found {
+				lt = append(lt, tag)
+			}
+		}
+		t.mu.RUnlock()
+		sort.Strings(lt)
+		t.mu.Lock()
+		// unless the messages changed meanwhile, making lt stale
+		if t.generation == generation {
+			t.available = lt
+		}
+		t.mu.Unlock()
+	}
+	return append([]string{}, lt...)
+}
+
+// AvailableBaseLanguages gets the list of the base languages provided by
+// the app, as ISO 639 codes without script or region ("zh" for "zh-hans-cn").
+func (t *Translator) AvailableBaseLanguages() []string {
+	bases := []string{}
+	seen := map[string]bool{}
+	for _, lang := range t.AvailableLanguages() {
+		if base := baseLanguage(lang); !seen[base] {
+			seen[base] = true
+			bases = append(bases, base)
+		}
+	}
+	sort.Strings(bases)
+	return bases
+}
+
+// CompleteLanguages gets the list of languages provided by the app, as
+// AvailableLanguages, that are translated enough to be offered to the
+// users: at least minCompleteness (0.8 for 80%) of the messages of the
+// default language, see Completeness.
+func (t *Translator) CompleteLanguages(minCompleteness float64) []string {
+	langs := []string{}
+	for _, lang := range t.AvailableLanguages() {
+		if t.Completeness(lang) >= minCompleteness {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
+
+// Completeness returns the share of the messages of the default language
+// that are translated in lang, from 0 to 1. The messages falling back to
+// another language don't count.
+func (t *Translator) Completeness(lang string) float64 {
+	def := t.defaultLanguage()
+	langs := language.Parse(lang)
+	if def == nil || len(langs) == 0 {
+		return 0
+	}
+	if err := t.loadLanguages(def.Tag, langs[0].Tag); err != nil {
+		t.logger().Error(err)
+	}
+
+	t.mu.RLock()
+	ids := make([]string, 0, len(t.messages[def.Tag]))
+	for id := range t.messages[def.Tag] {
+		ids = append(ids, id)
+	}
+	t.mu.RUnlock()
+	if len(ids) == 0 {
+		return 1
+	}
+	translated := 0
+	for _, id := range ids {
+		if t.lookupMessage(langs[0], id) != nil {
+			translated++
+		}
+	}
+	return float64(translated) / float64(len(ids))
+}
+
+// LoadedLanguages gets the list of languages with at least one message
+// loaded by this Translator. Unlike AvailableLanguages, it ignores the
+// locale files that are empty or failed to parse, as well as the languages
+// left for later by LazyLoad.
+func (t *Translator) LoadedLanguages() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	langs := make([]string, 0, len(t.messages))
+	for lang, msgs := range t.messages {
+		if len(msgs) > 0 {
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Negotiate matches the preferred languages against the available ones,
+// and returns the best match with the confidence of the match. For instance
+// "fr-CA" matches an available "fr-FR" with a High confidence, rather than
+// Exact: an app can use that to warn that the translations may not be the
+// ones the user expects. If nothing matches, the confidence is No.
+func (t *Translator) Negotiate(preferred []string) (xlanguage.Tag, xlanguage.Confidence) {
+	supported := []xlanguage.Tag{}
+	for _, lang := range t.AvailableLanguages() {
+		if tag, err := xlanguage.Parse(lang); err == nil {
+			supported = append(supported, tag)
+		}
+	}
+	if len(supported) == 0 {
+		return xlanguage.Und, xlanguage.No
+	}
+
+	prefs := []xlanguage.Tag{}
+	for _, lang := range preferred {
+		if tag, err := xlanguage.Parse(strings.TrimSpace(lang)); err == nil {
+			prefs = append(prefs, tag)
+		}
+	}
+	_, index, confidence := xlanguage.NewMatcher(supported).Match(prefs...)
+	return supported[index], confidence
+}
+
+// HreflangLink is an alternate-language version of a page, see Hreflang.
+type HreflangLink struct {
+	Lang string
+	URL  string
+}
+
+// Hreflang returns the URL of currentPath for each available language,
+// following the URLPrefixLanguageExtractor scheme ("/fr-fr/products").
+// If currentPath is already prefixed with a language, the prefix is
+// replaced. It is available in the views as the "hreflang" helper, to
+// render the <link rel="alternate" hreflang="..."> tags of a page.
+func (t *Translator) Hreflang(currentPath string) []HreflangLink {
+	langs := t.AvailableLanguages()
+
+	rest := "/" + strings.TrimPrefix(currentPath, "/")
+	segments := strings.SplitN(strings.TrimPrefix(rest, "/"), "/", 2)
+	if isPathLanguage(segments[0], langs) {
+		rest = "/"
+		if len(segments) > 1 {
+			rest += segments[1]
+		}
+	}
+
+	links := make([]HreflangLink, 0, len(langs))
+	for _, lang := range langs {
+		links = append(links, HreflangLink{
+			Lang: lang,
+			URL:  "/" + lang + strings.TrimSuffix(rest, "/"),
+		})
+	}
+	return links
+}
+
+// isPathLanguage tells whether a URL path segment is a prefix for one of
+// the given language tags, either as the full tag or as its base language.
+func isPathLanguage(segment string, langs []string) bool {
+	segment = language.NormalizeTag(segment)
+	if segment == "" {
+		return false
+	}
+	for _, lang := range langs {
+		if lang == segment || strings.HasPrefix(lang, segment+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// LanguageName returns the name of targetLang, written in displayLang:
+// LanguageName("en", "de") is "German", and LanguageName("de", "de") is
+// "Deutsch". It returns targetLang when a tag is invalid or the name is
+// unknown. It is available as the "languageName" view helper.
+func LanguageName(displayLang, targetLang string) string {
+	target, err := xlanguage.Parse(targetLang)
+	if err != nil {
+		return targetLang
+	}
+	display, err := xlanguage.Parse(displayLang)
+	if err != nil {
+		return targetLang
+	}
+	if name := xdisplay.Tags(display).Name(target); name != "" {
+		return name
+	}
+	return targetLang
 }
 
 // Refresh updates the context, reloading translation functions.
@@ -185,31 +1975,388 @@ func (t *Translator) AvailableLanguages() []string {
 // in the new language (for a flash message, for instance).
 func (t *Translator) Refresh(c buffalo.Context, newLang string) {
 	langs := []string{newLang}
-	langs = append(langs, t.extractLanguage(c)...)
+	langs = t.contextLanguages(append(langs, t.extractLanguage(c)...))
 
 	// Refresh languages
 	c.Set("languages", langs)
 
-	T, err := i18n.Tfunc(langs[0], langs[1:]...)
+	T, lang, err := t.tfuncAndLanguage(langs...)
 	if err != nil {
-		c.Logger().Warn(err)
-		c.Logger().Warn("Your locale files are probably empty or missing")
+		t.contextLogger(c).Warn(err)
+		t.contextLogger(c).Warn("Your locale files are probably empty or missing")
 	}
 
 	// Refresh translation engine
+	t.setState(c, &requestState{languages: langs, T: T, lang: lang})
 	c.Set("T", T)
+	c.Set(languageKey, lang)
+	setContentLanguage(c, lang)
+}
+
+// setContentLanguage declares the language of the response in the
+// Content-Language header.
+func setContentLanguage(c buffalo.Context, lang *language.Language) {
+	if lang == nil {
+		return
+	}
+	tag := lang.Tag
+	// prefer the canonical form of the tag ("fr-FR" rather than "fr-fr")
+	if t, err := xlanguage.Parse(tag); err == nil {
+		tag = t.String()
+	}
+	c.Response().Header().Set("Content-Language", tag)
+}
+
+// SetLanguage persists the language chosen by the user in a cookie, named
+// after the "CookieName" option, then refreshes the context for this
+// language (see Refresh). The cookie attributes are taken from
+// t.LanguageCookie.
+func (t *Translator) SetLanguage(c buffalo.Context, lang string) error {
+	cookieName, _ := t.LanguageExtractorOptions["CookieName"].(string)
+	if cookieName == "" {
+		return fmt.Errorf("i18n middleware: \"CookieName\" is not defined in LanguageExtractorOptions")
+	}
+
+	cookie := t.LanguageCookie
+	cookie.Name = cookieName
+	cookie.Value = lang
+	http.SetCookie(c.Response(), &cookie)
+
+	t.Refresh(c, lang)
+	return nil
 }
 
+// SwitchLanguageHandler returns a handler for the language pickers: it
+// persists the "lang" parameter (query or form) with SetLanguage, then
+// redirects to the "redirect" parameter, or to "/". The language must be
+// supported (see IsSupported), and is saved in canonical form ("fr-CA" for
+// "fr-ca"). The redirection must stay on the site: an absolute URL, or a
+// path like "//evil.com", is replaced with "/".
+//
+//	app.POST("/language", transl.SwitchLanguageHandler())
+func (t *Translator) SwitchLanguageHandler() buffalo.Handler {
+	return func(c buffalo.Context) error {
+		lang := c.Param("lang")
+		if !t.IsSupported(lang) {
+			return c.Error(http.StatusBadRequest, fmt.Errorf("i18n: unsupported language %q", lang))
+		}
+		// save the tag, rather than the parameter as sent
+		if tag, err := xlanguage.Parse(lang); err == nil {
+			lang = tag.String()
+		}
+		if err := t.SetLanguage(c, lang); err != nil {
+			return err
+		}
+		return c.Redirect(http.StatusSeeOther, localRedirect(c.Param("redirect")))
+	}
+}
+
+// IsSupported tells whether lang, e.g. picked by the user in a form, is a
+// valid BCP 47 tag matching one of the AvailableLanguages by base language:
+// "fr-CA" is supported if "fr-FR" is available, "fr-<script>" isn't. Check
+// it before persisting a language, so that a cookie doesn't hold a junk
+// value forever.
+func (t *Translator) IsSupported(lang string) bool {
+	if validateTag(lang) != nil {
+		return false
+	}
+	for _, available := range t.AvailableLanguages() {
+		if baseLanguage(available) == baseLanguage(lang) {
+			return true
+		}
+	}
+	return false
+}
+
+// localRedirect returns target if it is a path of the site, or "/": the
+// redirections to other sites are refused.
+func localRedirect(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "" || u.Host != "" || !strings.HasPrefix(target, "/") ||
+		strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "/"
+	}
+	return target
+}
+
+// extractLanguage returns the languages of the user, from the most to the
+// least preferred. The results of the extractors are merged as follows:
+//
+//  1. the languages of the WeightedLanguageExtractors, by weight;
+//  2. the languages of the explicit LanguageExtractors (cookie, session,
+//     URL...), in the extractors order;
+//  3. the languages negotiated by HeaderLanguageExtractor, by q-value:
+//     an explicit choice always beats the browser preferences;
+//  4. the default language, unless PreferDefault puts it first.
+//
+// Empty and undetermined ("und") languages are removed, as well as
+// duplicates, keeping the most preferred occurrence. The list is capped at
+// maxLanguages, so extractors looping over the same languages can't make it
+// unbounded.
 func (t *Translator) extractLanguage(c buffalo.Context) []string {
-	langs := []string{}
+	return languageTags(t.extractLanguageSources(c))
+}
+
+// extractLanguageSources is like extractLanguage, but it tells which
+// extractor found each language.
+func (t *Translator) extractLanguageSources(c buffalo.Context) []LanguageSource {
+	o := t.extractorOptions()
+
+	found := []LanguageSource{}
+	for _, extractor := range t.WeightedLanguageExtractors {
+		for _, wl := range extractor(o, c) {
+			found = append(found, LanguageSource{Tag: wl.Tag, Source: extractorName(extractor), Weight: wl.Weight})
+		}
+	}
+	// keep the extractors order for languages with the same weight
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Weight > found[j].Weight
+	})
+
+	negotiated := []LanguageSource{}
 	for _, extractor := range t.LanguageExtractors {
-		langs = append(langs, extractor(t.LanguageExtractorOptions, c)...)
+		sources := []LanguageSource{}
+		for _, lang := range extractor(o, c) {
+			sources = append(sources, LanguageSource{Tag: lang, Source: extractorName(extractor)})
+		}
+		if isHeaderExtractor(extractor) {
+			negotiated = append(negotiated, sources...)
+			continue
+		}
+		found = append(found, sources...)
+	}
+	found = append(found, negotiated...)
+
+	// the chain keeps the first occurrence of the languages, so does bySource
+	langs := make([]string, 0, len(found))
+	bySource := map[string]LanguageSource{}
+	for _, ls := range found {
+		langs = append(langs, ls.Tag)
+		tag := language.NormalizeTag(strings.TrimSpace(ls.Tag))
+		if _, ok := bySource[tag]; !ok {
+			bySource[tag] = ls
+		}
+	}
+	chain := t.languageChain(langs)
+	sources := make([]LanguageSource, 0, len(chain))
+	for _, lang := range chain {
+		ls, ok := bySource[language.NormalizeTag(strings.TrimSpace(lang))]
+		if !ok {
+			ls = LanguageSource{Tag: lang, Source: "default"}
+		}
+		sources = append(sources, ls)
+	}
+	return sources
+}
+
+// languageTags returns the tags of sources.
+func languageTags(sources []LanguageSource) []string {
+	tags := make([]string, 0, len(sources))
+	for _, ls := range sources {
+		tags = append(tags, ls.Tag)
+	}
+	return tags
+}
+
+// contextLanguages returns the languages to store in the "languages"
+// context value: langs as is, or in canonical form with CanonicalLanguages.
+func (t *Translator) contextLanguages(langs []string) []string {
+	if !t.CanonicalLanguages {
+		return langs
+	}
+	canonical := make([]string, 0, len(langs)+1)
+	seen := map[string]bool{}
+	for _, lang := range append(langs, t.DefaultLanguage) {
+		tag, err := xlanguage.Parse(strings.Replace(strings.TrimSpace(lang), "_", "-", -1))
+		if err != nil || tag == xlanguage.Und || seen[tag.String()] {
+			continue
+		}
+		seen[tag.String()] = true
+		canonical = append(canonical, tag.String())
+	}
+	if len(canonical) == 0 {
+		// keep the default language, however invalid, for T
+		return []string{t.DefaultLanguage}
+	}
+	return canonical
+}
+
+// languageChain cleans up the languages of the user, see extractLanguage,
+// and completes them with the default language.
+func (t *Translator) languageChain(langs []string) []string {
+	if t.PreferDefault {
+		langs = append([]string{t.DefaultLanguage}, langs...)
+	}
+	langs = dedupeLanguages(dropUndetermined(langs))
+	if len(langs) >= maxLanguages {
+		langs = langs[:maxLanguages-1]
 	}
 	// Add default language, even if no language extractor is defined
-	langs = append(langs, t.DefaultLanguage)
+	langs = dedupeLanguages(append(langs, t.DefaultLanguage))
+
+	if t.FilterAvailable {
+		langs = t.filterAvailable(langs)
+	}
 	return langs
 }
 
+// NegotiateRequest returns the languages of the user of r, from the most to
+// the least preferred, as the middleware does with the Accept-Language
+// header. Along with Tfunc, it allows to use a Translator in a net/http
+// application, without buffalo.
+func (t *Translator) NegotiateRequest(r *http.Request) []string {
+	return t.languageChain(parseAcceptLanguage(r.Header.Get("Accept-Language")))
+}
+
+// Tfunc returns a translation function for the first supported language of
+// langs, e.g. as returned by NegotiateRequest. The default language is used
+// when none of them is supported.
+func (t *Translator) Tfunc(langs ...string) i18n.TranslateFunc {
+	langs = append(langs, t.DefaultLanguage)
+	// without any supported language, T renders the message IDs
+	T, _, _ := t.tfuncAndLanguage(langs...)
+	return T
+}
+
+// extractorOptions returns the options given to the extractors: a copy of
+// t.LanguageExtractorOptions, so the reserved keys can be set safely.
+func (t *Translator) extractorOptions() LanguageExtractorOptions {
+	o := make(LanguageExtractorOptions, len(t.LanguageExtractorOptions)+1)
+	for k, v := range t.LanguageExtractorOptions {
+		o[k] = v
+	}
+	o[AvailableLanguagesOption] = t.AvailableLanguages()
+	if t.SilentMode {
+		o[silentOption] = true
+	}
+	return o
+}
+
+// Explain describes how the languages of the request of c are negotiated,
+// to debug the language selection: it returns the languages found by each
+// extractor, by extractor name, along with the resulting languages
+// ("chain") and the language of the translations ("matched").
+func (t *Translator) Explain(c buffalo.Context) map[string][]string {
+	o := t.extractorOptions()
+
+	explanation := map[string][]string{}
+	name := func(extractor interface{}) string {
+		name := extractorName(extractor)
+		unique := name
+		for i := 2; explanation[unique] != nil; i++ {
+			unique = fmt.Sprintf("%s#%d", name, i)
+		}
+		return unique
+	}
+	for _, extractor := range t.WeightedLanguageExtractors {
+		langs := []string{}
+		for _, wl := range extractor(o, c) {
+			langs = append(langs, wl.Tag)
+		}
+		explanation[name(extractor)] = langs
+	}
+	for _, extractor := range t.LanguageExtractors {
+		explanation[name(extractor)] = append([]string{}, extractor(o, c)...)
+	}
+
+	chain := t.extractLanguage(c)
+	explanation["chain"] = chain
+	explanation["matched"] = []string{}
+	if len(chain) > 0 {
+		if _, lang, err := t.tfuncAndLanguage(chain...); err == nil {
+			explanation["matched"] = []string{lang.Tag}
+		}
+	}
+	return explanation
+}
+
+// extractorName returns the name of the extractor function, such as
+// "i18n.CookieLanguageExtractor".
+func extractorName(extractor interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(extractor).Pointer()).Name()
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// exactMatch tells whether lang, the language of the translations, is the
+// preferred language pref: "fr" matches "fr-FR" translations, but "fr-BE"
+// doesn't.
+func exactMatch(pref string, lang *language.Language) bool {
+	if lang == nil {
+		return false
+	}
+	for _, l := range language.Parse(pref) {
+		if l.Tag == lang.Tag {
+			return true
+		}
+	}
+	return false
+}
+
+// isHeaderExtractor tells whether extractor is HeaderLanguageExtractor,
+// whose languages are negotiated rather than explicitly chosen.
+func isHeaderExtractor(extractor LanguageExtractor) bool {
+	return reflect.ValueOf(extractor).Pointer() == reflect.ValueOf(HeaderLanguageExtractor).Pointer()
+}
+
+// maxLanguages caps the number of languages of a request, default language
+// included, however many languages the extractors return.
+const maxLanguages = 10
+
+// dropUndetermined removes the empty and undetermined ("und") languages,
+// which can't match anything useful.
+func dropUndetermined(langs []string) []string {
+	determined := make([]string, 0, len(langs))
+	for _, lang := range langs {
+		if tag := language.NormalizeTag(strings.TrimSpace(lang)); tag != "" && tag != "und" {
+			determined = append(determined, lang)
+		}
+	}
+	return determined
+}
+
+// dedupeLanguages removes the duplicated languages, keeping the first
+// occurrence.
+func dedupeLanguages(langs []string) []string {
+	seen := map[string]bool{}
+	deduped := make([]string, 0, len(langs))
+	for _, lang := range langs {
+		tag := language.NormalizeTag(strings.TrimSpace(lang))
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		deduped = append(deduped, lang)
+	}
+	return deduped
+}
+
+// filterAvailable removes the languages that don't match any of the available
+// languages by base language.
+func (t *Translator) filterAvailable(langs []string) []string {
+	available := map[string]bool{}
+	for _, lang := range t.AvailableLanguages() {
+		available[baseLanguage(lang)] = true
+	}
+
+	filtered := make([]string, 0, len(langs))
+	for _, lang := range langs {
+		if available[baseLanguage(lang)] {
+			filtered = append(filtered, lang)
+		}
+	}
+	// the languages list must never be empty
+	if len(filtered) == 0 || t.KeepDefaultLanguage && !available[baseLanguage(t.DefaultLanguage)] {
+		filtered = append(filtered, t.DefaultLanguage)
+	}
+	return filtered
+}
+
+// baseLanguage returns the normalized base language subtag of tag
+// ("en" for "en-US").
+func baseLanguage(tag string) string {
+	return strings.SplitN(language.NormalizeTag(strings.TrimSpace(tag)), "-", 2)[0]
+}
+
 // CookieLanguageExtractor is a LanguageExtractor implementation, using a cookie.
 func CookieLanguageExtractor(o LanguageExtractorOptions, c buffalo.Context) []string {
 	langs := make([]string, 0)
@@ -221,21 +2368,50 @@ func CookieLanguageExtractor(o LanguageExtractorOptions, c buffalo.Context) []st
 			}
 		}
 	} else {
-		c.Logger().Error("i18n middleware: \"CookieName\" is not defined in LanguageExtractorOptions")
+		logMissingOption(o, c, "CookieName")
 	}
 	return langs
 }
 
+// logMissingOption reports that the option name, needed by an extractor, is
+// missing, at the level given by the LogLevelOption option.
+func logMissingOption(o LanguageExtractorOptions, c buffalo.Context, name string) {
+	if silent, _ := o[silentOption].(bool); silent {
+		return
+	}
+	msg := fmt.Sprintf("i18n middleware: %q is not defined in LanguageExtractorOptions", name)
+	level, ok := o[LogLevelOption].(logger.Level)
+	if !ok {
+		level = logger.WarnLevel
+	}
+	l := requestLogger(c)
+	switch level {
+	case logger.DebugLevel:
+		l.Debug(msg)
+	case logger.InfoLevel:
+		l.Info(msg)
+	case logger.WarnLevel:
+		l.Warn(msg)
+	default:
+		l.Error(msg)
+	}
+}
+
 // SessionLanguageExtractor is a LanguageExtractor implementation, using a session.
 func SessionLanguageExtractor(o LanguageExtractorOptions, c buffalo.Context) []string {
 	langs := make([]string, 0)
 	// try to get the language from the session
 	if sessionName := o["SessionName"].(string); sessionName != "" {
 		if s := c.Session().Get(sessionName); s != nil {
-			langs = append(langs, s.(string))
+			if value, ok := o[SessionValueOption].(func(interface{}) string); ok {
+				s = value(s)
+			}
+			if lang, ok := s.(string); ok && lang != "" {
+				langs = append(langs, lang)
+			}
 		}
 	} else {
-		c.Logger().Error("i18n middleware: \"SessionName\" is not defined in LanguageExtractorOptions")
+		logMissingOption(o, c, "SessionName")
 	}
 	return langs
 }
@@ -252,33 +2428,176 @@ func HeaderLanguageExtractor(o LanguageExtractorOptions, c buffalo.Context) []st
 	return langs
 }
 
+// HeaderWeightedLanguageExtractor is a WeightedLanguageExtractor implementation,
+// using a HTTP Accept-Language header. Languages are weighted with their
+// q-values (1 if omitted).
+func HeaderWeightedLanguageExtractor(o LanguageExtractorOptions, c buffalo.Context) []WeightedLanguage {
+	return parseAcceptLanguageWeights(c.Request().Header.Get("Accept-Language"))
+}
+
+// WithWeight turns a LanguageExtractor into a WeightedLanguageExtractor,
+// giving the same weight to all the languages it finds. For instance, to
+// make an explicit cookie choice beat any Accept-Language preference:
+//
+//	t.WeightedLanguageExtractors = []i18n.WeightedLanguageExtractor{
+//		i18n.WithWeight(i18n.CookieLanguageExtractor, 2),
+//		i18n.HeaderWeightedLanguageExtractor,
+//	}
+func WithWeight(extractor LanguageExtractor, weight float64) WeightedLanguageExtractor {
+	return func(o LanguageExtractorOptions, c buffalo.Context) []WeightedLanguage {
+		langs := extractor(o, c)
+		wls := make([]WeightedLanguage, 0, len(langs))
+		for _, lang := range langs {
+			wls = append(wls, WeightedLanguage{Tag: lang, Weight: weight})
+		}
+		return wls
+	}
+}
+
 // URLPrefixLanguageExtractor is a LanguageExtractor implementation, using a prefix in the URL.
+// The language is the route parameter named by the "URLPrefixName" option
+// ("/{lang}/products"). When the route has no such parameter, the first
+// segment of the path is used if it is one of the available languages (by
+// base language): "/fr/products" gives "fr", "/products/1" gives nothing.
 func URLPrefixLanguageExtractor(o LanguageExtractorOptions, c buffalo.Context) []string {
 	langs := make([]string, 0)
 	// try to get the language from an URL prefix:
 	if urlPrefixName := o["URLPrefixName"].(string); urlPrefixName != "" {
 		paramLang := c.Param(urlPrefixName)
+		if paramLang == "" {
+			available, _ := o[AvailableLanguagesOption].([]string)
+			paramLang = pathLanguage(c.Request().URL.Path, available)
+		}
 		if paramLang != "" && strings.HasPrefix(c.Request().URL.Path, fmt.Sprintf("/%s", paramLang)) {
 			langs = append(langs, paramLang)
 		}
 	} else {
-		c.Logger().Error("i18n middleware: \"URLPrefixName\" is not defined in LanguageExtractorOptions")
+		logMissingOption(o, c, "URLPrefixName")
+	}
+	return langs
+}
+
+// RouteParamLanguageExtractor is a LanguageExtractor implementation, using
+// the route parameter named by the "RouteParamName" option ("locale" by
+// default), as in "/{locale}/products". The parameter is ignored unless it
+// is a well-formed language tag.
+func RouteParamLanguageExtractor(o LanguageExtractorOptions, c buffalo.Context) []string {
+	langs := make([]string, 0)
+	if paramName, _ := o["RouteParamName"].(string); paramName != "" {
+		if lang := c.Param(paramName); lang != "" && validateTag(lang) == nil {
+			langs = append(langs, lang)
+		}
+	} else {
+		logMissingOption(o, c, "RouteParamName")
 	}
 	return langs
 }
 
+// pathLanguage returns the first segment of path if it is a language
+// matching one of available by base language, or an empty string.
+func pathLanguage(path string, available []string) string {
+	segment := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+	if segment == "" || len(language.Parse(segment)) == 0 {
+		return ""
+	}
+	for _, lang := range available {
+		if baseLanguage(lang) == baseLanguage(segment) {
+			return segment
+		}
+	}
+	return ""
+}
+
 // Inspired from https://siongui.github.io/2015/02/22/go-parse-accept-language/
-// Parse an Accept-Language string to get usable lang values for i18n system
+// Parse an Accept-Language string to get usable lang values for i18n system.
+// The languages are sorted by q-value, and the ones with q=0 (not acceptable)
+// are removed.
 func parseAcceptLanguage(acptLang string) []string {
+	wls := parseAcceptLanguageWeights(acptLang)
+	// keep the header order for languages with the same q-value
+	sort.SliceStable(wls, func(i, j int) bool {
+		return wls[i].Weight > wls[j].Weight
+	})
+
 	var lqs []string
+	for _, wl := range wls {
+		if wl.Weight > 0 {
+			lqs = append(lqs, wl.Tag)
+		}
+	}
+	return lqs
+}
+
+// stripExtensions removes the extensions and the private use subtags of a
+// language tag, which the locale files never have, so that it still matches
+// them: "en-US-u-ca-gregory" or "en-US-x-private" is "en-US". A private use
+// tag such as "x-klingon" has no language, and is dropped.
+func stripExtensions(tag string) string {
+	subtags := strings.Split(tag, "-")
+	for i, subtag := range subtags {
+		// a singleton subtag introduces an extension, or private use
+		if len(subtag) == 1 {
+			return strings.Join(subtags[:i], "-")
+		}
+	}
+	return tag
+}
 
-	langQStrs := strings.Split(acptLang, ",")
-	for _, langQStr := range langQStrs {
-		trimedLangQStr := strings.Trim(langQStr, " ")
+// maxAcceptLanguages caps the number of languages read from an
+// Accept-Language header, which is untrusted input: the remaining ones are
+// ignored.
+const maxAcceptLanguages = 100
 
-		langQ := strings.Split(trimedLangQStr, ";")
-		lq := langQ[0]
-		lqs = append(lqs, lq)
+// parseAcceptLanguageWeights parses an Accept-Language string, keeping the
+// q-value of each language. Languages that aren't well-formed tags, or with
+// an invalid q-value (not a number from 0 to 1), are skipped.
+func parseAcceptLanguageWeights(acptLang string) []WeightedLanguage {
+	wls := make([]WeightedLanguage, 0)
+	entries := strings.SplitN(acptLang, ",", maxAcceptLanguages+1)
+	if len(entries) > maxAcceptLanguages {
+		entries = entries[:maxAcceptLanguages]
 	}
-	return lqs
+	for _, langQStr := range entries {
+		langQ := strings.Split(strings.TrimSpace(langQStr), ";")
+		lang := stripExtensions(strings.TrimSpace(langQ[0]))
+		if !wellFormedTag(lang) {
+			continue
+		}
+
+		weight := 1.0
+		if len(langQ) > 1 {
+			q := strings.TrimSpace(langQ[1])
+			if !strings.HasPrefix(q, "q=") {
+				continue
+			}
+			w, err := strconv.ParseFloat(strings.TrimPrefix(q, "q="), 64)
+			if err != nil || math.IsNaN(w) || w < 0 || w > 1 {
+				continue
+			}
+			weight = w
+		}
+		wls = append(wls, WeightedLanguage{Tag: lang, Weight: weight})
+	}
+	return wls
+}
+
+// wellFormedTag tells whether tag has the syntax of a language tag: subtags
+// of 1 to 8 ASCII letters or digits separated by hyphens, the first one
+// made of letters. It doesn't tell whether the language exists.
+func wellFormedTag(tag string) bool {
+	if tag == "" {
+		return false
+	}
+	for i, subtag := range strings.Split(tag, "-") {
+		if len(subtag) == 0 || len(subtag) > 8 {
+			return false
+		}
+		for _, r := range subtag {
+			letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+			if !letter && (i == 0 || r < '0' || r > '9') {
+				return false
+			}
+		}
+	}
+	return true
 }
@@ -1,18 +1,40 @@
 package i18n_test
 
 import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
+	nethttptest "net/http/httptest"
+	"net/url"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"text/template"
 	"time"
+	"unicode/utf16"
 
 	"github.com/gobuffalo/middleware/i18n"
 
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/buffalo/render"
 	"github.com/gobuffalo/httptest"
+	"github.com/gobuffalo/logger"
+	goi18n "github.com/nicksnyder/go-i18n/i18n"
+	goi18nlanguage "github.com/nicksnyder/go-i18n/i18n/language"
+	"github.com/nicksnyder/go-i18n/i18n/translation"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
 )
 
 type User struct {
@@ -59,6 +81,50 @@ func app() *buffalo.App {
 	app.GET("/languages-list", func(c buffalo.Context) error {
 		return c.Render(200, r.JSON(t.AvailableLanguages()))
 	})
+	app.GET("/hreflang", func(c buffalo.Context) error {
+		return c.Render(200, r.HTML("hreflang.html"))
+	})
+	app.GET("/ordinal", func(c buffalo.Context) error {
+		places := []string{}
+		for _, n := range []int{1, 2, 3, 4, 11, 21} {
+			places = append(places, t.TranslateOrdinal(c, "finished-place", n, User{FirstName: "Mark"}))
+		}
+		return c.Render(200, r.JSON(places))
+	})
+	app.GET("/translatef", func(c buffalo.Context) error {
+		s, err := t.Translatef(c, "legacy-welcome", "Mark", 3)
+		if err != nil {
+			return err
+		}
+		return c.Render(200, r.String(s))
+	})
+	app.GET("/localize", func(c buffalo.Context) error {
+		s, err := t.LocalizeWith(c, &i18n.LocalizeConfig{
+			MessageID:    "greeting-plural",
+			PluralCount:  3,
+			TemplateData: map[string]interface{}{"Unused": true},
+		})
+		if err != nil {
+			return err
+		}
+		shout, err := t.LocalizeWith(c, &i18n.LocalizeConfig{
+			MessageID:      "localize-shout",
+			DefaultMessage: `{{upper "hello"}}, {{.Name}}!`,
+			TemplateData:   map[string]interface{}{"Name": "Mark"},
+			Funcs:          template.FuncMap{"upper": strings.ToUpper},
+		})
+		if err != nil {
+			return err
+		}
+		return c.Render(200, r.String(s+"#"+shout))
+	})
+	app.GET("/labels", func(c buffalo.Context) error {
+		labels, err := t.TranslateMap(c, []string{"greeting", "refresh-success", "missing-label"})
+		if err != nil {
+			return err
+		}
+		return c.Render(200, r.JSON(labels))
+	})
 	app.GET("/refresh", func(c buffalo.Context) error {
 		// This flash will be displayed in english
 		c.Flash().Add("success", t.Translate(c, "refresh-success"))
@@ -250,6 +316,2965 @@ func Test_Refresh(t *testing.T) {
 	r.Equal("success: Language changed!#success: Langue modifiée !#", strings.TrimSpace(res.Body.String()))
 }
 
+func Test_Load_PartialErrors(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"partial.en-us.yaml": {Data: []byte("- id: partial-ok\n  translation: \"Loaded anyway\"\n")},
+		"broken.fr-fr.yaml":  {Data: []byte("- id: [broken\n")},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.Error(err)
+
+	var errs i18n.LoadErrors
+	r.ErrorAs(err, &errs)
+	r.Len(errs, 1)
+	r.Contains(errs[0].Error(), "broken.fr-fr.yaml")
+
+	res, err := transl.TranslateWithLang("en-us", "partial-ok")
+	r.NoError(err)
+	r.Equal("Loaded anyway", res)
+}
+
+func Test_ExportMessages(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"export.en-us.yaml": {Data: []byte(`- id: export-post
+  description: "Verb: publish a new article"
+  translation: "Post"
+
+- id: export-orders
+  translation:
+    one: "{{.Count}} order"
+    other: "{{.Count}} orders"
+`)},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+
+	msgs := transl.ExportMessages("en-US")
+	r.Equal([]i18n.ExportedMessage{
+		{
+			ID:     "export-orders",
+			Plural: map[string]string{"one": "{{.Count}} order", "other": "{{.Count}} orders"},
+		},
+		{
+			ID:          "export-post",
+			Description: "Verb: publish a new article",
+			Translation: "Post",
+		},
+	}, msgs)
+
+	r.Empty(transl.ExportMessages("fr-fr"))
+}
+
+func Test_i18n_Hreflang(t *testing.T) {
+	r := require.New(t)
+
+	w := httptest.New(app())
+	res := w.HTML("/hreflang").Get()
+	r.True(eq(`<link rel="alternate" hreflang="en-us" href="/en-us/hreflang">
+<link rel="alternate" hreflang="fr-fr" href="/fr-fr/hreflang">`, res.Body.String()))
+
+	fsys := fstest.MapFS{
+		"hreflang.en-us.yaml": {Data: []byte("- id: hreflang-greeting\n  translation: \"Hello!\"\n")},
+		"hreflang.fr-fr.yaml": {Data: []byte("- id: hreflang-greeting\n  translation: \"Bonjour !\"\n")},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+	r.Equal([]i18n.HreflangLink{
+		{Lang: "en-us", URL: "/en-us/products/1"},
+		{Lang: "fr-fr", URL: "/fr-fr/products/1"},
+	}, transl.Hreflang("/fr/products/1"))
+	r.Equal([]i18n.HreflangLink{
+		{Lang: "en-us", URL: "/en-us"},
+		{Lang: "fr-fr", URL: "/fr-fr"},
+	}, transl.Hreflang("/en-us/"))
+}
+
+func Test_i18n_WeightedLanguageExtractors(t *testing.T) {
+	r := require.New(t)
+
+	app := buffalo.New(buffalo.Options{})
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	transl.LanguageExtractors = nil
+	transl.WeightedLanguageExtractors = []i18n.WeightedLanguageExtractor{
+		i18n.WithWeight(i18n.CookieLanguageExtractor, 2),
+		i18n.HeaderWeightedLanguageExtractor,
+	}
+	app.Use(transl.Middleware())
+	app.GET("/languages", func(c buffalo.Context) error {
+		return c.Render(200, render.JSON(c.Value("languages")))
+	})
+
+	w := httptest.New(app)
+	w.Cookies = "lang=es"
+	req := w.HTML("/languages")
+	req.Headers["Accept-Language"] = "de;q=0.5, fr-fr;q=0.8, en-GB"
+	res := req.Get()
+	r.Equal(`["es","en-GB","fr-fr","de","en-US"]`, strings.TrimSpace(res.Body.String()))
+}
+
+func Test_i18n_FilterAvailable(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "de-DE")
+	r.NoError(err)
+	transl.FilterAvailable = true
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/languages", func(c buffalo.Context) error {
+		return c.Render(200, render.JSON(c.Value("languages")))
+	})
+
+	w := httptest.New(app)
+	req := w.HTML("/languages")
+	req.Headers["Accept-Language"] = "ru, fr-CA, it;q=0.8, en"
+	res := req.Get()
+	r.Equal(`["fr-CA","en"]`, strings.TrimSpace(res.Body.String()))
+
+	transl.KeepDefaultLanguage = true
+	res = req.Get()
+	r.Equal(`["fr-CA","en","de-DE"]`, strings.TrimSpace(res.Body.String()))
+}
+
+func Test_LoadMap(t *testing.T) {
+	r := require.New(t)
+
+	transl := i18n.Translator{}
+	err := transl.LoadMap(map[string]map[string]string{
+		"en-US": {
+			"map-greeting": "Hello {{.Name}}!",
+		},
+		"fr-FR": {
+			"map-greeting": "Bonjour {{.Name}} !",
+			"map-broken":   "Bonjour {{.Name !",
+		},
+		"klingon": {
+			"map-greeting": "nuqneH",
+		},
+	})
+	r.Error(err)
+	r.Len(err.(i18n.LoadErrors), 2)
+
+	data := map[string]interface{}{"Name": "Mark"}
+	res, err := transl.TranslateWithLang("en-us", "map-greeting", data)
+	r.NoError(err)
+	r.Equal("Hello Mark!", res)
+
+	res, err = transl.TranslateWithLang("fr-fr", "map-greeting", data)
+	r.NoError(err)
+	r.Equal("Bonjour Mark !", res)
+}
+
+func Test_SetLanguage(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	transl.LanguageCookie.Secure = true
+	transl.LanguageCookie.MaxAge = 3600
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/set-language", func(c buffalo.Context) error {
+		if err := transl.SetLanguage(c, "fr-fr"); err != nil {
+			return err
+		}
+		return c.Render(200, render.String(transl.Translate(c, "greeting")))
+	})
+
+	w := httptest.New(app)
+	res := w.HTML("/set-language").Get()
+	r.Equal("Bonjour à tous !", res.Body.String())
+	r.Equal("lang=fr-fr; Path=/; Max-Age=3600; Secure; SameSite=Lax", res.Header().Get("Set-Cookie"))
+
+	transl.LanguageExtractorOptions["CookieName"] = ""
+	res = w.HTML("/set-language").Get()
+	r.Equal(500, res.Code)
+}
+
+func Test_LoadedLanguages(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"loaded.en-us.yaml": {Data: []byte("- id: loaded-greeting\n  translation: \"Hello!\"\n")},
+		"empty.fr-fr.yaml":  {Data: []byte{}},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+
+	r.Equal([]string{"en-us"}, transl.LoadedLanguages())
+	r.Contains(transl.AvailableLanguages(), "fr-fr")
+}
+
+func Test_i18n_TranslateOrdinal(t *testing.T) {
+	r := require.New(t)
+
+	w := httptest.New(app())
+	res := w.HTML("/ordinal").Get()
+	r.Equal(`["You finished 1st, Mark!","You finished 2nd, Mark!","You finished 3rd, Mark!","You finished 4th, Mark!","You finished 11th, Mark!","You finished 21st, Mark!"]`, strings.TrimSpace(res.Body.String()))
+
+	req := w.HTML("/ordinal")
+	req.Headers["Accept-Language"] = "fr-fr"
+	res = req.Get()
+	r.Equal(`["Vous avez fini 1er, Mark !","Vous avez fini 2e, Mark !","Vous avez fini 3e, Mark !","Vous avez fini 4e, Mark !","Vous avez fini 11e, Mark !","Vous avez fini 21e, Mark !"]`, strings.TrimSpace(res.Body.String()))
+}
+
+func Test_AvailableLanguages_Cache(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	r.NotContains(transl.AvailableLanguages(), "it")
+
+	err = transl.LoadMap(map[string]map[string]string{
+		"it": {"cache-greeting": "Ciao!"},
+	})
+	r.NoError(err)
+	r.Contains(transl.AvailableLanguages(), "it")
+}
+
+func Test_AvailableLanguages_Cache_Concurrent(t *testing.T) {
+	r := require.New(t)
+
+	langs := []string{"cs", "da", "de", "es", "fi", "it", "nl", "pl", "pt", "sv"}
+	for i := 0; i < 20; i++ {
+		transl, err := i18n.New(fstest.MapFS{}, "en-US")
+		r.NoError(err)
+
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+						transl.AvailableLanguages()
+					}
+				}
+			}()
+		}
+		for _, lang := range langs {
+			r.NoError(transl.LoadMap(map[string]map[string]string{lang: {"cache-concurrent": lang}}))
+		}
+		close(done)
+		wg.Wait()
+		// no stale list was cached
+		r.Subset(transl.AvailableLanguages(), langs)
+	}
+}
+
+func Test_Load_DirectoryPerLanguage(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"en-us/messages.yaml":    {Data: []byte("- id: dir-greeting\n  translation: \"Hello from a directory\"\n")},
+		"fr-fr/messages.yaml":    {Data: []byte("- id: dir-greeting\n  translation: \"Bonjour depuis un dossier\"\n")},
+		"fr-fr/other.en-us.yaml": {Data: []byte("- id: dir-override\n  translation: \"File name wins\"\n")},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+
+	res, err := transl.TranslateWithLang("en-us", "dir-greeting")
+	r.NoError(err)
+	r.Equal("Hello from a directory", res)
+
+	res, err = transl.TranslateWithLang("fr-fr", "dir-greeting")
+	r.NoError(err)
+	r.Equal("Bonjour depuis un dossier", res)
+
+	res, err = transl.TranslateWithLang("en-us", "dir-override")
+	r.NoError(err)
+	r.Equal("File name wins", res)
+}
+
+func Test_Load_RequiredLanguages(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"required.en-us.yaml": {Data: []byte("- id: required-greeting\n  translation: \"Hello!\"\n")},
+		"optional.fr-fr.yaml": {Data: []byte("- id: [broken\n")},
+	}
+	transl := &i18n.Translator{
+		FS:                fsys,
+		DefaultLanguage:   "en-US",
+		RequiredLanguages: []string{"en"},
+	}
+	r.NoError(transl.Load())
+	r.Equal([]string{"en-us"}, transl.LoadedLanguages())
+
+	fsys["broken.en-us.yaml"] = &fstest.MapFile{Data: []byte("- id: [broken\n")}
+	err := transl.Load()
+	r.Error(err)
+	r.Len(err.(i18n.LoadErrors), 1)
+	r.Contains(err.Error(), "broken.en-us.yaml")
+}
+
+func Test_Negotiate(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	tag, conf := transl.Negotiate([]string{"fr-FR", "en"})
+	r.Equal("fr-FR", tag.String())
+	r.Equal(language.Exact, conf)
+
+	tag, conf = transl.Negotiate([]string{"fr-CA"})
+	r.Equal("fr-FR", tag.String())
+	r.Equal(language.High, conf)
+
+	_, conf = transl.Negotiate([]string{"ja", "not a language"})
+	r.Equal(language.No, conf)
+}
+
+func Test_APIMiddleware(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.APIMiddleware())
+	app.GET("/api", func(c buffalo.Context) error {
+		r.Nil(c.Value(transl.HelperName))
+		r.Nil(c.Value("hreflang"))
+		return c.Render(200, render.JSON(map[string]interface{}{
+			"languages": c.Value("languages"),
+			"greeting":  transl.Translate(c, "greeting"),
+		}))
+	})
+
+	w := httptest.New(app)
+	req := w.JSON("/api")
+	req.Headers["Accept-Language"] = "fr-fr"
+	res := req.Get()
+	r.Equal(`{"greeting":"Bonjour à tous !","languages":["fr-fr","en-US"]}`, strings.TrimSpace(res.Body.String()))
+}
+
+func Test_i18n_Translatef(t *testing.T) {
+	r := require.New(t)
+
+	w := httptest.New(app())
+	res := w.HTML("/translatef").Get()
+	r.Equal("Welcome Mark, you have 3 new messages.", res.Body.String())
+
+	req := w.HTML("/translatef")
+	req.Headers["Accept-Language"] = "fr-fr"
+	res = req.Get()
+	r.Equal("Bienvenue Mark, vous avez 3 nouveaux messages.", res.Body.String())
+}
+
+func Test_i18n_Extractor_AvailableLanguages(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	// prefer the user's region only if it is available
+	transl.LanguageExtractors = []i18n.LanguageExtractor{
+		func(o i18n.LanguageExtractorOptions, c buffalo.Context) []string {
+			available := o[i18n.AvailableLanguagesOption].([]string)
+			for _, lang := range available {
+				if lang == "fr-fr" {
+					return []string{"fr-fr"}
+				}
+			}
+			return []string{"fr"}
+		},
+	}
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/languages", func(c buffalo.Context) error {
+		return c.Render(200, render.JSON(c.Value("languages")))
+	})
+
+	w := httptest.New(app)
+	res := w.HTML("/languages").Get()
+	r.Equal(`["fr-fr","en-US"]`, strings.TrimSpace(res.Body.String()))
+	r.NotContains(transl.LanguageExtractorOptions, i18n.AvailableLanguagesOption)
+}
+
+func Test_i18n_ReloadDebounce(t *testing.T) {
+	r := require.New(t)
+
+	yaml := func(s string) []byte {
+		return []byte("- id: reload-greeting\n  translation: \"" + s + "\"\n")
+	}
+	fsys := fstest.MapFS{
+		"reload.en-us.yaml": {Data: yaml("Before"), ModTime: time.Now().Add(-time.Hour)},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{Env: "development"})
+	app.Use(transl.Middleware())
+	app.GET("/reload", func(c buffalo.Context) error {
+		return c.Render(200, render.String(transl.Translate(c, "reload-greeting")))
+	})
+	w := httptest.New(app)
+	r.Equal("Before", w.HTML("/reload").Get().Body.String())
+
+	// the file is being written
+	fsys["reload.en-us.yaml"] = &fstest.MapFile{Data: yaml("Aft"), ModTime: time.Now()}
+	r.Equal("Before", w.HTML("/reload").Get().Body.String())
+
+	// the file has been stable long enough
+	fsys["reload.en-us.yaml"] = &fstest.MapFile{Data: yaml("After"), ModTime: time.Now().Add(-time.Second)}
+	r.Equal("After", w.HTML("/reload").Get().Body.String())
+}
+
+// readCountFS counts how many times each file is read.
+type readCountFS struct {
+	fstest.MapFS
+	read map[string]int
+}
+
+func (fsys readCountFS) ReadFile(name string) ([]byte, error) {
+	fsys.read[name]++
+	return fsys.MapFS.ReadFile(name)
+}
+
+func Test_i18n_IncrementalReload(t *testing.T) {
+	r := require.New(t)
+
+	yaml := func(id, s string) []byte {
+		return []byte("- id: " + id + "\n  translation: \"" + s + "\"\n")
+	}
+	old := time.Now().Add(-time.Hour)
+	fsys := readCountFS{
+		MapFS: fstest.MapFS{
+			"incremental.en-us.yaml": {Data: yaml("incremental-greeting", "Before"), ModTime: old},
+			"incremental.fr-fr.yaml": {Data: yaml("incremental-greeting", "Avant"), ModTime: old},
+			"extra.fr-fr.yaml":       {Data: yaml("incremental-extra", "En plus"), ModTime: old},
+		},
+		read: map[string]int{},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+	transl.IncrementalReload = true
+
+	app := buffalo.New(buffalo.Options{Env: "development"})
+	app.Use(transl.Middleware())
+	app.GET("/reload", func(c buffalo.Context) error {
+		return c.Render(200, render.String(transl.Translate(c, "incremental-greeting")))
+	})
+	w := httptest.New(app)
+	req := w.HTML("/reload")
+	req.Headers["Accept-Language"] = "fr-fr"
+	r.Equal("Avant", req.Get().Body.String())
+
+	fsys.MapFS["incremental.fr-fr.yaml"] = &fstest.MapFile{Data: yaml("incremental-greeting", "Après"), ModTime: time.Now().Add(-time.Second)}
+	delete(fsys.MapFS, "extra.fr-fr.yaml")
+	r.Equal("Après", req.Get().Body.String())
+
+	// only the changed file was read again
+	r.Equal(2, fsys.read["incremental.fr-fr.yaml"])
+	r.Equal(1, fsys.read["incremental.en-us.yaml"])
+
+	ids := []string{}
+	for _, m := range transl.ExportMessages("fr-fr") {
+		ids = append(ids, m.ID)
+	}
+	r.Equal([]string{"incremental-greeting"}, ids)
+	r.Len(transl.ExportMessages("en-us"), 1)
+	// the messages of the removed file aren't translated anymore
+	s, err := transl.TranslateWithLang("fr-fr", "incremental-extra")
+	r.NoError(err)
+	r.Equal("incremental-extra", s)
+}
+
+func Test_i18n_Load_RemovedMessage(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"removed.en-us.yaml": {Data: []byte("- id: removed-kept\n  translation: Kept\n- id: removed-gone\n  translation: Gone\n")},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+	s, err := transl.TranslateWithLang("en-us", "removed-gone")
+	r.NoError(err)
+	r.Equal("Gone", s)
+
+	fsys["removed.en-us.yaml"] = &fstest.MapFile{Data: []byte("- id: removed-kept\n  translation: Kept\n")}
+	r.NoError(transl.Load())
+	s, err = transl.TranslateWithLang("en-us", "removed-gone")
+	r.NoError(err)
+	r.Equal("removed-gone", s)
+	s, err = transl.TranslateWithLang("en-us", "removed-kept")
+	r.NoError(err)
+	r.Equal("Kept", s)
+}
+
+func Test_i18n_FallbackTranslations(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/untranslated", func(c buffalo.Context) error {
+		return c.Render(200, render.String(transl.Translate(c, "untranslated")))
+	})
+	w := httptest.New(app)
+
+	req := w.HTML("/untranslated")
+	req.Headers["Accept-Language"] = "fr-fr"
+	r.Equal("untranslated", req.Get().Body.String())
+
+	transl.FallbackTranslations = true
+	r.Equal("Not translated yet", req.Get().Body.String())
+}
+
+func Test_Merge(t *testing.T) {
+	r := require.New(t)
+
+	newTranslator := func(msgs string) *i18n.Translator {
+		transl, err := i18n.New(fstest.MapFS{"merge.en-us.yaml": {Data: []byte(msgs)}}, "en-US")
+		r.NoError(err)
+		return transl
+	}
+	translate := func(transl *i18n.Translator, id string) string {
+		s, err := transl.TranslateWithLang("en-us", id)
+		r.NoError(err)
+		return s
+	}
+
+	base := newTranslator("- id: merge-shared\n  translation: Base\n")
+	plugin := newTranslator("- id: merge-shared\n  translation: Plugin\n\n- id: merge-plugin\n  translation: From plugin\n")
+
+	base.ConflictPolicy = i18n.FirstWins
+	r.NoError(base.Merge(plugin))
+	r.Equal("Base", translate(base, "merge-shared"))
+	r.Equal("From plugin", translate(base, "merge-plugin"))
+	r.Equal([]i18n.ExportedMessage{
+		{ID: "merge-plugin", Translation: "From plugin"},
+		{ID: "merge-shared", Translation: "Base"},
+	}, base.ExportMessages("en-us"))
+
+	app := newTranslator("- id: merge-shared\n  translation: App\n")
+	r.NoError(app.Merge(plugin))
+	r.Equal("Plugin", translate(app, "merge-shared"))
+	// the translators don't share their messages
+	r.Equal("Base", translate(base, "merge-shared"))
+	source, ok := app.MessageSource("en-us", "merge-plugin")
+	r.True(ok)
+	r.Equal("merge.en-us.yaml", source)
+	r.Equal([]i18n.ExportedMessage{
+		{ID: "merge-plugin", Translation: "From plugin"},
+		{ID: "merge-shared", Translation: "Plugin"},
+	}, app.ExportMessages("en-us"))
+
+	// the plugin's own messages are left untouched
+	r.Equal([]i18n.ExportedMessage{
+		{ID: "merge-plugin", Translation: "From plugin"},
+		{ID: "merge-shared", Translation: "Plugin"},
+	}, plugin.ExportMessages("en-us"))
+}
+
+func Test_TranslateAll(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	all := transl.TranslateAll("greeting-plural", 5)
+	r.Equal("Hello, 5 people!", all["en-us"])
+	r.Equal("Bonjour, 5 personnes !", all["fr-fr"])
+	r.Len(all, len(transl.AvailableLanguages()))
+}
+
+func Test_i18n_Languages_Overwritten(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(func(next buffalo.Handler) buffalo.Handler {
+		return func(c buffalo.Context) error {
+			c.Set("languages", "fr, en")
+			return next(c)
+		}
+	})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.String(transl.Translate(c, "greeting")))
+	})
+
+	w := httptest.New(app)
+	req := w.HTML("/")
+	req.Headers["Accept-Language"] = "fr-fr"
+	res := req.Get()
+	r.Equal(200, res.Code)
+	r.Equal("Bonjour à tous !", res.Body.String())
+}
+
+func Test_i18n_Languages_MergePolicy(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	// the header extractor comes first, but explicit choices still win
+	transl.LanguageExtractors = []i18n.LanguageExtractor{
+		i18n.HeaderLanguageExtractor,
+		i18n.CookieLanguageExtractor,
+	}
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/languages", func(c buffalo.Context) error {
+		return c.Render(200, render.JSON(c.Value("languages")))
+	})
+
+	w := httptest.New(app)
+	w.Cookies = "lang=fr"
+	req := w.HTML("/languages")
+	req.Headers["Accept-Language"] = "de;q=0.5, en-US;q=0.9, fr;q=0.8, es;q=0, it"
+	res := req.Get()
+	r.Equal(`["fr","it","en-US","de"]`, strings.TrimSpace(res.Body.String()))
+}
+
+func Test_AvailableBaseLanguages(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	r.NoError(transl.LoadMap(map[string]map[string]string{
+		"fr-ca":   {"base-greeting": "Allô!"},
+		"zh-Hans": {"base-greeting": "你好"},
+	}))
+
+	bases := transl.AvailableBaseLanguages()
+	r.Contains(bases, "en")
+	r.Contains(bases, "fr")
+	r.Contains(bases, "zh")
+	r.NotContains(bases, "fr-fr")
+	r.NotContains(bases, "zh-hans")
+	r.True(sort.StringsAreSorted(bases))
+}
+
+func Test_i18n_LocalizeWith(t *testing.T) {
+	r := require.New(t)
+
+	w := httptest.New(app())
+	res := w.HTML("/localize").Get()
+	r.Equal("Hello, 3 people!#HELLO, Mark!", res.Body.String())
+
+	req := w.HTML("/localize")
+	req.Headers["Accept-Language"] = "fr-fr"
+	res = req.Get()
+	r.Equal("Bonjour, 3 personnes !#HELLO, Mark!", res.Body.String())
+}
+
+func Test_i18n_Languages_Undetermined(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	transl.LanguageExtractors = []i18n.LanguageExtractor{
+		func(o i18n.LanguageExtractorOptions, c buffalo.Context) []string {
+			return []string{"", "und", " UND ", "fr-fr"}
+		},
+	}
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/languages", func(c buffalo.Context) error {
+		return c.Render(200, render.JSON(c.Value("languages")))
+	})
+
+	w := httptest.New(app)
+	res := w.HTML("/languages").Get()
+	r.Equal(`["fr-fr","en-US"]`, strings.TrimSpace(res.Body.String()))
+}
+
+func Test_i18n_ContentLanguage(t *testing.T) {
+	r := require.New(t)
+
+	w := httptest.New(app())
+	res := w.HTML("/").Get()
+	r.Equal("en-US", res.Header().Get("Content-Language"))
+
+	req := w.HTML("/")
+	req.Headers["Accept-Language"] = "ru, fr-fr;q=0.8"
+	res = req.Get()
+	r.Equal("fr-FR", res.Header().Get("Content-Language"))
+
+	// Refresh updates the header
+	res = w.HTML("/refresh").Get()
+	r.Equal("fr-FR", res.Header().Get("Content-Language"))
+}
+
+func Test_i18n_TranslateMap(t *testing.T) {
+	r := require.New(t)
+
+	w := httptest.New(app())
+	req := w.HTML("/labels")
+	req.Headers["Accept-Language"] = "fr-fr"
+	res := req.Get()
+	r.Equal(`{"greeting":"Bonjour à tous !","missing-label":"missing-label","refresh-success":"Langue modifiée !"}`, strings.TrimSpace(res.Body.String()))
+
+	// without the middleware
+	transl := i18n.Translator{}
+	_, err := transl.TranslateMap(&buffalo.DefaultContext{Context: context.Background()}, []string{"greeting"})
+	r.Error(err)
+}
+
+func Test_LanguageName(t *testing.T) {
+	r := require.New(t)
+
+	r.Equal("German", i18n.LanguageName("en", "de"))
+	r.Equal("Deutsch", i18n.LanguageName("de", "de"))
+	r.Equal("allemand", i18n.LanguageName("fr-FR", "de"))
+	r.Equal("Canadian French", i18n.LanguageName("en-US", "fr-CA"))
+	r.Equal("not a tag", i18n.LanguageName("en", "not a tag"))
+	r.Equal("de", i18n.LanguageName("not a tag", "de"))
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/name", func(c buffalo.Context) error {
+		name := c.Value("languageName").(func(string, string) string)
+		return c.Render(200, render.String(name("en", "it")))
+	})
+	w := httptest.New(app)
+	r.Equal("Italian", w.HTML("/name").Get().Body.String())
+}
+
+func Test_i18n_IDTransform(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	transl.IDTransform = func(id string) string {
+		return strings.ReplaceAll(strings.TrimPrefix(id, "home."), ".", "-")
+	}
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.String(transl.Translate(c, "home.refresh.success")))
+	})
+	w := httptest.New(app)
+	req := w.HTML("/")
+	req.Headers["Accept-Language"] = "fr-fr"
+	r.Equal("Langue modifiée !", req.Get().Body.String())
+}
+
+func Test_i18n_Languages_Capped(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	// a misconfigured fallback chain: fr -> de -> fr -> de...
+	circular := func(o i18n.LanguageExtractorOptions, c buffalo.Context) []string {
+		langs := []string{}
+		for i := 0; i < 1000; i++ {
+			langs = append(langs, "fr", "de")
+		}
+		return langs
+	}
+	// a huge Accept-Language header
+	many := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		many = append(many, fmt.Sprintf("en-%03d", i))
+	}
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/languages", func(c buffalo.Context) error {
+		return c.Render(200, render.JSON(c.Value("languages")))
+	})
+	w := httptest.New(app)
+
+	transl.LanguageExtractors = []i18n.LanguageExtractor{circular}
+	r.Equal(`["fr","de","en-US"]`, strings.TrimSpace(w.HTML("/languages").Get().Body.String()))
+
+	transl.LanguageExtractors = []i18n.LanguageExtractor{i18n.HeaderLanguageExtractor}
+	req := w.HTML("/languages")
+	req.Headers["Accept-Language"] = strings.Join(many, ",")
+	var langs []string
+	r.NoError(json.Unmarshal(req.Get().Body.Bytes(), &langs))
+	r.Len(langs, 10)
+	r.Equal("en-000", langs[0])
+	r.Equal("en-US", langs[9])
+}
+
+func Test_i18n_TranslateTZ(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	r.NoError(transl.LoadMap(map[string]map[string]string{
+		"en-us": {"meeting-start": `The meeting starts at {{.Start.Format "15:04"}}`},
+	}))
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	r.NoError(err)
+	start := time.Date(2021, time.March, 1, 9, 30, 0, 0, time.UTC)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/tz", func(c buffalo.Context) error {
+		s := transl.TranslateTZ(c, "meeting-start", paris, map[string]interface{}{"Start": start})
+		s += "\n" + transl.TranslateTZ(c, "meeting-start", paris, struct{ Start time.Time }{start})
+		s += "\n" + transl.TranslateTZ(c, "meeting-start", nil, map[string]interface{}{"Start": start})
+		return c.Render(200, render.String(s))
+	})
+	w := httptest.New(app)
+	r.Equal("The meeting starts at 10:30\nThe meeting starts at 10:30\nThe meeting starts at 09:30", w.HTML("/tz").Get().Body.String())
+}
+
+func Test_NewStrict(t *testing.T) {
+	r := require.New(t)
+
+	for _, tag := range []string{"en-US", "en-us", "fr", "zh-Hans"} {
+		transl, err := i18n.NewStrict(os.DirFS("locales"), tag)
+		r.NoError(err, tag)
+		r.Equal(tag, transl.DefaultLanguage)
+	}
+	for _, tag := range []string{"english", "en_US", "en-US-", "und", ""} {
+		_, err := i18n.NewStrict(os.DirFS("locales"), tag)
+		r.Error(err, tag)
+	}
+}
+
+func Test_i18n_NetHTTP(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		T := transl.Tfunc(transl.NegotiateRequest(req)...)
+		fmt.Fprint(w, T("greeting"))
+	})
+
+	req := nethttptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Language", "de;q=0.5, fr-fr")
+	r.Equal([]string{"fr-fr", "de", "en-US"}, transl.NegotiateRequest(req))
+	res := nethttptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	r.Equal("Bonjour à tous !", res.Body.String())
+
+	req.Header.Del("Accept-Language")
+	r.Equal([]string{"en-US"}, transl.NegotiateRequest(req))
+	res = nethttptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	r.Equal("Hello, World!", res.Body.String())
+
+	r.Equal("Hello, World!", transl.Tfunc("xx")("greeting"))
+}
+
+func Test_i18n_LogLevelOption(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	transl.LanguageExtractorOptions["CookieName"] = ""
+
+	out := &bytes.Buffer{}
+	l := logrus.New()
+	l.SetOutput(out)
+	l.SetLevel(logrus.DebugLevel)
+	l.SetFormatter(&logrus.TextFormatter{DisableColors: true})
+	app := buffalo.New(buffalo.Options{Logger: logger.Logrus{FieldLogger: l}})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.String(transl.Translate(c, "greeting")))
+	})
+	w := httptest.New(app)
+
+	w.HTML("/").Get()
+	r.Contains(out.String(), `level=warning msg="i18n middleware: \"CookieName\" is not defined`)
+
+	out.Reset()
+	transl.LanguageExtractorOptions[i18n.LogLevelOption] = logger.DebugLevel
+	w.HTML("/").Get()
+	r.Contains(out.String(), `level=debug msg="i18n middleware: \"CookieName\" is not defined`)
+}
+
+func Test_i18n_GlobalTemplateData(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	r.NoError(transl.LoadMap(map[string]map[string]string{
+		"en-us": {"brand-welcome": "Welcome to {{.AppName}}, {{.Name}}!"},
+	}))
+	transl.GlobalTemplateData = map[string]interface{}{"AppName": "Acme", "Name": "guest"}
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		s := transl.Translate(c, "brand-welcome")
+		s += "\n" + transl.Translate(c, "brand-welcome", map[string]interface{}{"Name": "Mark"})
+		s += "\n" + transl.Translate(c, "brand-welcome", struct{ Name string }{"Ann"})
+		return c.Render(200, render.String(s))
+	})
+	w := httptest.New(app)
+	r.Equal("Welcome to Acme, guest!\nWelcome to Acme, Mark!\nWelcome to Acme, Ann!", w.HTML("/").Get().Body.String())
+
+	s, err := transl.TranslateWithLang("en-us", "brand-welcome")
+	r.NoError(err)
+	r.Equal("Welcome to Acme, guest!", s)
+}
+
+func Test_i18n_MissingThisRequest(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		for _, id := range []string{"greeting", "missing-one", "missing-two", "missing-one"} {
+			transl.Translate(c, id)
+		}
+		return c.Render(200, render.JSON(i18n.MissingThisRequest(c)))
+	})
+	w := httptest.New(app)
+	r.Equal("null", strings.TrimSpace(w.HTML("/").Get().Body.String()))
+
+	transl.TrackMissing = true
+	r.Equal(`["missing-one","missing-two"]`, strings.TrimSpace(w.HTML("/").Get().Body.String()))
+	// every request starts afresh
+	r.Equal(`["missing-one","missing-two"]`, strings.TrimSpace(w.HTML("/").Get().Body.String()))
+}
+
+//go:embed locales
+var localesFS embed.FS
+
+func Test_NewEmbed(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.NewEmbed(localesFS, "locales", "en-US")
+	r.NoError(err)
+	s, err := transl.TranslateWithLang("fr-fr", "greeting")
+	r.NoError(err)
+	r.Equal("Bonjour à tous !", s)
+
+	_, err = i18n.NewEmbed(localesFS, "missing", "en-US")
+	r.Error(err)
+	_, err = i18n.NewEmbed(localesFS, "locales/test.en-us.yaml", "en-US")
+	r.Error(err)
+}
+
+func Test_i18n_CaseInsensitiveIDs(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"case.en-us.yaml": {Data: []byte(`
+- id: Case.Title
+  description: the title of the page
+  translation: "Mixed case"
+- id: Case.Items
+  translation:
+    one: "{{.Count}} item"
+    other: "{{.Count}} items"
+`)},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+	transl.CaseInsensitiveIDs = true
+	r.NoError(transl.Load())
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		s := transl.Translate(c, "case.title")
+		s += "\n" + transl.Translate(c, "CASE.TITLE")
+		s += "\n" + transl.Translate(c, "Case.Items", 2)
+		return c.Render(200, render.String(s))
+	})
+	w := httptest.New(app)
+	r.Equal("Mixed case\nMixed case\n2 items", w.HTML("/").Get().Body.String())
+
+	msgs := transl.ExportMessages("en-us")
+	r.Len(msgs, 2)
+	r.Equal("case.items", msgs[0].ID)
+	r.Equal("case.title", msgs[1].ID)
+	r.Equal("the title of the page", msgs[1].Description)
+}
+
+func Test_i18n_ValidatePlurals(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"plurals.en-us.yaml": {Data: []byte(`
+- id: plurals-files
+  translation:
+    one: "{{.Count}} file"
+    other: "{{.Count}} files"
+`)},
+		"plurals.ru.yaml": {Data: []byte(`
+- id: plurals-files
+  translation:
+    one: "{{.Count}} файл"
+    other: "{{.Count}} файла"
+- id: plurals-title
+  translation: "Файлы"
+`)},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+
+	errs := transl.ValidatePlurals()
+	r.Len(errs, 2)
+	r.EqualError(errs[0], `i18n: message "plurals-files" in ru is missing the plural form "few"`)
+	r.EqualError(errs[1], `i18n: message "plurals-files" in ru is missing the plural form "many"`)
+}
+
+func Test_i18n_TranslateEach(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		labels, err := transl.TranslateEach(c, []string{"refresh-success", "greeting", "missing-label"})
+		if err != nil {
+			return err
+		}
+		return c.Render(200, render.JSON(labels))
+	})
+	app.GET("/options", func(c buffalo.Context) error {
+		c.Set("statuses", []string{"greeting", "refresh-success"})
+		return c.Render(200, render.New(render.Options{}).String(`<%= for (s) in translateEach(statuses) { %><option><%= s %></option><% } %>`))
+	})
+	w := httptest.New(app)
+
+	req := w.HTML("/")
+	req.Headers["Accept-Language"] = "fr-fr"
+	r.Equal(`["Langue modifiée !","Bonjour à tous !","missing-label"]`, strings.TrimSpace(req.Get().Body.String()))
+
+	req = w.HTML("/options")
+	req.Headers["Accept-Language"] = "fr-fr"
+	r.Equal("<option>Bonjour à tous !</option><option>Langue modifiée !</option>", req.Get().Body.String())
+
+	_, err = transl.TranslateEach(&buffalo.DefaultContext{Context: context.Background()}, []string{"greeting"})
+	r.Error(err)
+}
+
+func Test_i18n_TranslateDefault(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		s := transl.TranslateDefault(c, "greeting", "Hi!")
+		s += "\n" + transl.TranslateDefault(c, "inline-welcome", "Welcome {{.Name}}!", map[string]interface{}{"Name": "Mark"})
+		s += "\n" + transl.TranslateDefault(c, "inline-count", "{{.Count}} new messages", 3)
+		return c.Render(200, render.String(s))
+	})
+	w := httptest.New(app)
+	req := w.HTML("/")
+	req.Headers["Accept-Language"] = "fr-fr"
+	r.Equal("Bonjour à tous !\nWelcome Mark!\n3 new messages", req.Get().Body.String())
+}
+
+func Test_NewLazy(t *testing.T) {
+	r := require.New(t)
+
+	yaml := func(s string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte("- id: lazy-greeting\n  translation: \"" + s + "\"\n")}
+	}
+	fsys := readCountFS{
+		MapFS: fstest.MapFS{
+			"lazy.en-us.yaml": yaml("Hello"),
+			"lazy.de.yaml":    yaml("Hallo"),
+			"lazy.nl.yaml":    yaml("Hoi"),
+		},
+		read: map[string]int{},
+	}
+	transl, err := i18n.NewLazy(fsys, "en-US")
+	r.NoError(err)
+	r.Empty(fsys.read)
+	r.Subset(transl.AvailableLanguages(), []string{"de", "en-us", "nl"})
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.String(transl.Translate(c, "lazy-greeting")))
+	})
+	w := httptest.New(app)
+	req := w.HTML("/")
+	req.Headers["Accept-Language"] = "de"
+	r.Equal("Hallo", req.Get().Body.String())
+	r.Equal("Hallo", req.Get().Body.String())
+	r.Equal(1, fsys.read["lazy.de.yaml"])
+	r.Equal(0, fsys.read["lazy.nl.yaml"])
+
+	s, err := transl.TranslateWithLang("nl", "lazy-greeting")
+	r.NoError(err)
+	r.Equal("Hoi", s)
+	r.Equal(1, fsys.read["lazy.nl.yaml"])
+
+	// in development, the changed files are parsed again when used
+	dev := buffalo.New(buffalo.Options{Env: "development"})
+	transl.IncrementalReload = true
+	dev.Use(transl.Middleware())
+	dev.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.String(transl.Translate(c, "lazy-greeting")))
+	})
+	fsys.MapFS["lazy.de.yaml"] = yaml("Guten Tag")
+	fsys.MapFS["lazy.de.yaml"].ModTime = time.Now().Add(-time.Second)
+	req = httptest.New(dev).HTML("/")
+	req.Headers["Accept-Language"] = "de"
+	r.Equal("Guten Tag", req.Get().Body.String())
+	r.Equal(2, fsys.read["lazy.de.yaml"])
+	r.Equal(1, fsys.read["lazy.nl.yaml"])
+}
+
+func Test_NewLazy_Concurrent(t *testing.T) {
+	r := require.New(t)
+
+	langs := []string{"de", "en-us", "es", "fr", "it", "nl", "pt", "sv"}
+	for i := 0; i < 20; i++ {
+		fsys := fstest.MapFS{}
+		for _, lang := range langs {
+			fsys["lazy."+lang+".yaml"] = &fstest.MapFile{Data: []byte("- id: lazy-concurrent\n  translation: " + lang + "\n")}
+		}
+		transl, err := i18n.NewLazy(fsys, "en-US")
+		r.NoError(err)
+
+		var wg sync.WaitGroup
+		for _, lang := range langs {
+			wg.Add(1)
+			go func(lang string) {
+				defer wg.Done()
+				s, err := transl.TranslateWithLang(lang, "lazy-concurrent")
+				if err != nil || s != lang {
+					t.Errorf("%s: %q, %v", lang, s, err)
+				}
+			}(lang)
+		}
+		wg.Wait()
+		// none of the concurrent loads was lost
+		r.Equal(langs, transl.LoadedLanguages())
+	}
+}
+
+func Test_i18n_SessionValueOption(t *testing.T) {
+	r := require.New(t)
+
+	type preferences struct {
+		Language string
+		Theme    string
+	}
+	// the session is saved with gob
+	gob.Register(preferences{})
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	transl.LanguageExtractorOptions[i18n.SessionValueOption] = func(v interface{}) string {
+		if p, ok := v.(preferences); ok {
+			return p.Language
+		}
+		return ""
+	}
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(func(next buffalo.Handler) buffalo.Handler {
+		return func(c buffalo.Context) error {
+			c.Session().Set("lang", preferences{Language: "fr-fr", Theme: "dark"})
+			return next(c)
+		}
+	})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.String(transl.Translate(c, "greeting")))
+	})
+	w := httptest.New(app)
+	r.Equal("Bonjour à tous !", w.HTML("/").Get().Body.String())
+}
+
+func Test_i18n_Explain(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"explain.en-us.yaml": {Data: []byte("- id: explain-greeting\n  translation: \"Hello!\"\n")},
+		"explain.fr-fr.yaml": {Data: []byte("- id: explain-greeting\n  translation: \"Bonjour !\"\n")},
+		"explain.it.yaml":    {Data: []byte("- id: explain-greeting\n  translation: \"Ciao!\"\n")},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+	transl.WeightedLanguageExtractors = []i18n.WeightedLanguageExtractor{
+		i18n.WithWeight(func(o i18n.LanguageExtractorOptions, c buffalo.Context) []string {
+			return []string{"it"}
+		}, 2),
+	}
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/explain", func(c buffalo.Context) error {
+		return c.Render(200, render.JSON(transl.Explain(c)))
+	})
+	w := httptest.New(app)
+	w.Cookies = "lang=fr-fr"
+	req := w.HTML("/explain")
+	req.Headers["Accept-Language"] = "es, en"
+
+	var explanation map[string][]string
+	r.NoError(json.Unmarshal(req.Get().Body.Bytes(), &explanation))
+	r.Equal(map[string][]string{
+		"i18n.WithWeight.func1":         {"it"},
+		"i18n.CookieLanguageExtractor":  {"fr-fr"},
+		"i18n.SessionLanguageExtractor": {},
+		"i18n.HeaderLanguageExtractor":  {"es", "en"},
+		"chain":                         {"it", "fr-fr", "es", "en", "en-US"},
+		"matched":                       {"it"},
+	}, explanation)
+}
+
+func Test_i18n_NoFallbackPrefixes(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	r.NoError(transl.LoadMap(map[string]map[string]string{
+		"en-us": {"legal.terms": "Terms of service"},
+	}))
+	transl.FallbackTranslations = true
+	transl.NoFallbackPrefixes = []string{"legal."}
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.JSON([]string{
+			transl.Translate(c, "legal.terms"),
+			transl.Translate(c, "untranslated"),
+		}))
+	})
+	w := httptest.New(app)
+
+	req := w.HTML("/")
+	req.Headers["Accept-Language"] = "fr-fr"
+	r.Equal(`["","Not translated yet"]`, strings.TrimSpace(req.Get().Body.String()))
+
+	req = w.HTML("/")
+	req.Headers["Accept-Language"] = "en-us"
+	r.Equal(`["Terms of service","Not translated yet"]`, strings.TrimSpace(req.Get().Body.String()))
+
+	// no locale file in Japanese: the response is in the default language
+	req = w.HTML("/")
+	req.Headers["Accept-Language"] = "ja-jp"
+	r.Equal(`["","Not translated yet"]`, strings.TrimSpace(req.Get().Body.String()))
+}
+
+func Test_i18n_LocalizeWith_Nested(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	r.NoError(transl.LoadMap(map[string]map[string]string{
+		"en-us": {
+			"nested.app-name": "Acme",
+			"nested.welcome":  `Welcome to {{call .t "nested.app-name"}}, {{call .t "nested.user" .}}!`,
+			"nested.user":     "{{.Name}}",
+			"nested.cycle-a":  `{{call .t "nested.cycle-b"}}`,
+			"nested.cycle-b":  `{{call .t "nested.cycle-a" .}}`,
+			"nested.own-t":    "{{.t}}",
+		},
+	}))
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		s, err := transl.LocalizeWith(c, &i18n.LocalizeConfig{
+			MessageID:    "nested.welcome",
+			TemplateData: map[string]interface{}{"Name": "Mark"},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = transl.LocalizeWith(c, &i18n.LocalizeConfig{MessageID: "nested.cycle-a"})
+		if err == nil {
+			return fmt.Errorf("circular messages should fail")
+		}
+		own, err := transl.LocalizeWith(c, &i18n.LocalizeConfig{
+			MessageID:    "nested.own-t",
+			TemplateData: map[string]interface{}{"t": "mine"},
+		})
+		if err != nil {
+			return err
+		}
+		c.Set("s", s+"|"+own+"|"+transl.Translate(c, "nested.welcome", map[string]interface{}{"Name": "Mark"}))
+		return c.Render(200, render.New(render.Options{}).String(`<%= s %>|<%= t("nested.welcome", {Name: "Mark"}) %>`))
+	})
+	w := httptest.New(app)
+	r.Equal("Welcome to Acme, Mark!|mine|Welcome to Acme, Mark!|Welcome to Acme, Mark!", w.HTML("/").Get().Body.String())
+}
+
+func Test_ParseBundleFiles(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"app.en-us.yaml": {Data: []byte(`
+- id: parse-title
+  description: the title of the page
+  translation: "Title"
+- id: parse-items
+  translation:
+    one: "{{.Count}} item"
+    other: "{{.Count}} items"
+`)},
+		"fr/app.yaml":    {Data: []byte("- id: parse-title\n  translation: \"Titre\"\n")},
+		"broken.it.yaml": {Data: []byte("{")},
+	}
+	files, err := i18n.ParseBundleFiles(fsys)
+	r.Error(err)
+	r.Len(files, 2)
+	r.Equal("app.en-us.yaml", files[0].Path)
+	r.Equal("en-us", files[0].Tag)
+	r.Equal([]i18n.ExportedMessage{
+		{ID: "parse-items", Plural: map[string]string{"one": "{{.Count}} item", "other": "{{.Count}} items"}},
+		{ID: "parse-title", Description: "the title of the page", Translation: "Title"},
+	}, files[0].Messages)
+	r.Equal("fr/app.yaml", files[1].Path)
+	r.Equal("fr", files[1].Tag)
+	r.Equal([]i18n.ExportedMessage{{ID: "parse-title", Translation: "Titre"}}, files[1].Messages)
+
+	// nothing was loaded, not even in the global bundle of go-i18n
+	T, _ := goi18n.Tfunc("en-us")
+	r.Equal("parse-title", T("parse-title"))
+}
+
+func Test_i18n_Helper_CountAndData(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		helper, ok := c.Value("t").(func(string, ...interface{}) string)
+		if !ok {
+			return fmt.Errorf("unexpected helper %T", c.Value("t"))
+		}
+		s := helper("greeting-plural", 1)
+		return c.Render(200, render.New(render.Options{}).String(s+`|<%= t("greeting-plural", 5, {Name: "Mark"}) %>|<%= t("greeting-plural", {Count: 5}) %>|<%= t("test-format", {Name: "Mark"}) %>`))
+	})
+
+	w := httptest.New(app)
+	res := w.HTML("/").Get()
+	r.Equal(200, res.Code)
+	r.Equal("Hello, alone!|Hello, 5 people!|Hello, 5 people!|Hello Mark!", res.Body.String())
+}
+
+func Test_ParseBundleFiles_IncludesAndDomains(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"_common.yaml": {Data: []byte("- id: parse-ok\n  translation: \"OK\"\n")},
+		"app.en-us.yaml": {Data: []byte(`
+- _include: [common.yaml]
+- id: parse-title
+  translation: "Title"
+`)},
+		"emails/app.en-us.yaml": {Data: []byte("- id: parse-title\n  translation: \"Subject\"\n")},
+	}
+
+	files, err := i18n.ParseBundleFiles(fsys, "emails")
+	r.NoError(err)
+	r.Len(files, 2)
+	r.Equal("app.en-us.yaml", files[0].Path)
+	r.Equal("en-us", files[0].Tag)
+	r.Equal([]i18n.ExportedMessage{
+		{ID: "parse-ok", Translation: "OK"},
+		{ID: "parse-title", Translation: "Title"},
+	}, files[0].Messages)
+	r.Equal("emails/app.en-us.yaml", files[1].Path)
+	r.Equal([]i18n.ExportedMessage{{ID: "emails:parse-title", Translation: "Subject"}}, files[1].Messages)
+}
+
+func Test_i18n_Load_BOM(t *testing.T) {
+	r := require.New(t)
+
+	yaml := "- id: bom-greeting\n  translation: \"Grüß Gott\"\n"
+	// UTF-16, little endian
+	le := []byte{0xff, 0xfe}
+	for _, c := range utf16.Encode([]rune(yaml)) {
+		le = append(le, byte(c), byte(c>>8))
+	}
+	fsys := fstest.MapFS{
+		"bom.de-at.yaml":   {Data: append([]byte("\xef\xbb\xbf"), yaml...)},
+		"utf16.de-ch.yaml": {Data: le},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+
+	for _, lang := range []string{"de-at", "de-ch"} {
+		s, err := transl.TranslateWithLang(lang, "bom-greeting")
+		r.NoError(err)
+		r.Equal("Grüß Gott", s, lang)
+	}
+}
+
+func Test_i18n_TwoTranslators(t *testing.T) {
+	r := require.New(t)
+
+	public, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	admin, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	admin.HelperName = "at"
+	admin.IDTransform = func(id string) string {
+		return "refresh-" + id
+	}
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(public.Middleware())
+	app.Use(admin.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		c.Set("ids", []string{"greeting"})
+		return c.Render(200, render.New(render.Options{}).String(`<%= t("greeting") %>|<%= at("success") %>|<%= translateEach(ids) %>`))
+	})
+	w := httptest.New(app)
+	req := w.HTML("/")
+	req.Headers["Accept-Language"] = "fr-fr"
+	r.Equal("Bonjour à tous !|Langue modifiée !|Bonjour à tous !", req.Get().Body.String())
+}
+
+func Test_i18n_TwoTranslators_OwnMessages(t *testing.T) {
+	r := require.New(t)
+
+	public, err := i18n.New(fstest.MapFS{
+		"public.en-us.yaml": {Data: []byte("- id: title\n  translation: Home\n")},
+		"public.fr-fr.yaml": {Data: []byte("- id: title\n  translation: Accueil\n")},
+	}, "en-US")
+	r.NoError(err)
+	admin, err := i18n.New(fstest.MapFS{
+		"admin.en-us.yaml": {Data: []byte("- id: title\n  translation: Dashboard\n")},
+	}, "en-US")
+	r.NoError(err)
+	admin.HelperName = "at"
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(public.Middleware())
+	app.Use(admin.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.New(render.Options{}).String(`<%= t("title") %>|<%= at("title") %>`))
+	})
+	w := httptest.New(app)
+	req := w.HTML("/")
+	req.Headers["Accept-Language"] = "fr-fr"
+	res := req.Get()
+	// the admin area isn't translated in French
+	r.Equal("Accueil|Dashboard", res.Body.String())
+	r.Equal("fr-FR", res.Header().Get("Content-Language"))
+}
+
+func Test_i18n_Namespace(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"lib.en-us.yaml": {Data: []byte(`
+- id: button.submit
+  description: the submit button of the forms
+  translation: "Send"
+`)},
+	}
+	lib, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+	lib.Namespace = "lib"
+	r.NoError(lib.Load())
+
+	app, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	r.NoError(app.Merge(lib))
+
+	s, err := app.TranslateWithLang("en-us", "lib.button.submit")
+	r.NoError(err)
+	r.Equal("Send", s)
+
+	msgs := lib.ExportMessages("en-us")
+	r.Len(msgs, 1)
+	r.Equal("lib.button.submit", msgs[0].ID)
+	r.Equal("the submit button of the forms", msgs[0].Description)
+}
+
+func Test_i18n_EmptyFS(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(fstest.MapFS{}, "eo")
+	r.NoError(err)
+	r.Contains(transl.AvailableLanguages(), "eo")
+	tag, conf := transl.Negotiate([]string{"eo"})
+	r.Equal("eo", tag.String())
+	r.Equal(language.Exact, conf)
+}
+
+func Test_i18n_EmptyFS_Middleware(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(fstest.MapFS{}, "eo")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.String(transl.Translate(c, "greeting")))
+	})
+
+	w := httptest.New(app)
+	w.Headers["Accept-Language"] = "eo"
+	res := w.HTML("/").Get()
+	r.Equal(200, res.Code)
+	r.Equal("greeting", res.Body.String())
+	r.Equal("eo", res.Header().Get("Content-Language"))
+}
+
+func Test_i18n_ContextTranslate(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.APIMiddleware())
+	app.GET("/", func(c buffalo.Context) error {
+		tr := c.Value("translate").(func(string, ...interface{}) (string, error))
+		s, err := tr("greeting")
+		if err != nil {
+			return err
+		}
+		_, err = tr("not-a-message")
+		return c.Render(200, render.String(s+" "+err.Error()))
+	})
+
+	w := httptest.New(app)
+	w.Cookies = "lang=fr-fr"
+	res := w.HTML("/").Get()
+	r.Equal(`Bonjour à tous ! i18n: no translation found for "not-a-message"`, res.Body.String())
+}
+
+func Test_FormatLocalCurrency(t *testing.T) {
+	r := require.New(t)
+
+	r.Equal("$ 1,234.50", i18n.FormatLocalCurrency("en-US", 1234.5))
+	r.Equal("￥ 1,235", i18n.FormatLocalCurrency("ja-JP", 1234.5))
+	r.Equal("€ 1\u00a0234,50", i18n.FormatLocalCurrency("fr", 1234.5))
+	r.Equal("€ 1,234.50", i18n.FormatLocalCurrency("en-US-u-cu-eur", 1234.5))
+}
+
+func Test_i18n_LanguageExactMatch(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.String(fmt.Sprint(c.Value("languageExactMatch"))))
+	})
+
+	w := httptest.New(app)
+	for lang, exact := range map[string]string{
+		"fr-fr": "true",
+		"fr":    "true",
+		"fr-BE": "false",
+		"xx":    "false",
+	} {
+		w.Cookies = "lang=" + lang
+		res := w.HTML("/").Get()
+		r.Equal(exact, res.Body.String(), lang)
+	}
+}
+
+func Test_i18n_TrimWhitespace(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"ws.en-us.yaml": {Data: []byte(`
+- id: ws-address
+  translation: "{{.Street}}  \r\n{{.City}}\t\r\n"
+- id: ws-items
+  translation:
+    one: "1 item  "
+    other: "{{.Count}} items  "
+`)},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+	transl.TrimWhitespace = true
+	r.NoError(transl.Load())
+
+	s, err := transl.TranslateWithLang("en-us", "ws-address", map[string]interface{}{"Street": "1 Main St", "City": "Springfield"})
+	r.NoError(err)
+	r.Equal("1 Main St\nSpringfield\n", s)
+	s, err = transl.TranslateWithLang("en-us", "ws-items", 2)
+	r.NoError(err)
+	r.Equal("2 items", s)
+}
+
+func Test_i18n_AddFile(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	r.NotContains(transl.AvailableLanguages(), "nl-be")
+
+	r.NoError(transl.AddFile("uploads/messages.nl-be.yaml", []byte(`
+- id: greeting
+  translation: "Hallo allemaal!"
+`)))
+	r.Contains(transl.AvailableLanguages(), "nl-be")
+	s, err := transl.TranslateWithLang("nl-be", "greeting")
+	r.NoError(err)
+	r.Equal("Hallo allemaal!", s)
+
+	// the added messages survive a reload
+	r.NoError(transl.Load())
+	r.Equal([]i18n.ExportedMessage{
+		{ID: "greeting", Translation: "Hallo allemaal!"},
+	}, transl.ExportMessages("nl-be"))
+
+	r.Error(transl.AddFile("messages.yaml", []byte(`[]`)))
+	r.Error(transl.AddFile("messages.nl-be.yaml", []byte(`{`)))
+}
+
+func Test_i18n_LanguageSources(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	transl.WeightedLanguageExtractors = []i18n.WeightedLanguageExtractor{
+		i18n.HeaderWeightedLanguageExtractor,
+	}
+	transl.LanguageExtractors = []i18n.LanguageExtractor{
+		i18n.CookieLanguageExtractor,
+	}
+
+	var sources []i18n.LanguageSource
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		sources = c.Value("languageSources").([]i18n.LanguageSource)
+		return c.Render(200, render.JSON(c.Value("languages")))
+	})
+
+	w := httptest.New(app)
+	w.Cookies = "lang=es"
+	req := w.HTML("/")
+	req.Headers["Accept-Language"] = "fr-fr, de;q=0.5"
+	res := req.Get()
+	r.Equal(`["fr-fr","de","es","en-US"]`, strings.TrimSpace(res.Body.String()))
+	r.Equal([]i18n.LanguageSource{
+		{Tag: "fr-fr", Source: "i18n.HeaderWeightedLanguageExtractor", Weight: 1},
+		{Tag: "de", Source: "i18n.HeaderWeightedLanguageExtractor", Weight: 0.5},
+		{Tag: "es", Source: "i18n.CookieLanguageExtractor"},
+		{Tag: "en-US", Source: "default"},
+	}, sources)
+}
+
+func Test_i18n_URLPrefixWithoutParam(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	transl.LanguageExtractors = []i18n.LanguageExtractor{i18n.URLPrefixLanguageExtractor}
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/fr/index", func(c buffalo.Context) error {
+		return c.Render(200, render.JSON(c.Value("languages")))
+	})
+	app.GET("/products/index", func(c buffalo.Context) error {
+		return c.Render(200, render.JSON(c.Value("languages")))
+	})
+
+	w := httptest.New(app)
+	res := w.HTML("/fr/index").Get()
+	r.Equal(`["fr","en-US"]`, strings.TrimSpace(res.Body.String()))
+	res = w.HTML("/products/index").Get()
+	r.Equal(`["en-US"]`, strings.TrimSpace(res.Body.String()))
+}
+
+func Test_PluralCategory(t *testing.T) {
+	r := require.New(t)
+
+	r.Equal("few", i18n.PluralCategory("pl", 3))
+	r.Equal("many", i18n.PluralCategory("pl", 5))
+	r.Equal("one", i18n.PluralCategory("pl", int64(1)))
+	r.Equal("other", i18n.PluralCategory("pl", 1.5))
+	r.Equal("one", i18n.PluralCategory("en-US", 1))
+	r.Equal("one", i18n.PluralCategory("en-US", "-1"))
+	r.Equal("other", i18n.PluralCategory("en-US", "1.0"))
+	r.Equal("other", i18n.PluralCategory("en-US", 5))
+	r.Equal("one", i18n.PluralCategory("fr", 1.5))
+	r.Equal("zero", i18n.PluralCategory("ar", 0))
+	r.Equal("other", i18n.PluralCategory("en-US", "five"))
+	r.Equal("other", i18n.PluralCategory("english", 1))
+}
+
+func Test_i18n_HelperNames(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	transl.HelperNames = []string{"translate_", "T"}
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.New(render.Options{}).String(`<%= t("greeting") %>|<%= translate_("greeting") %>|<%= T("greeting") %>`))
+	})
+	w := httptest.New(app)
+	req := w.HTML("/")
+	req.Headers["Accept-Language"] = "fr-fr"
+	r.Equal("Bonjour à tous !|Bonjour à tous !|Bonjour à tous !", req.Get().Body.String())
+}
+
+func Test_i18n_WithOverlay(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	transl.TenantSelector = func(c buffalo.Context) string {
+		return c.Param("tenant")
+	}
+	tr, err := translation.NewTranslation(map[string]interface{}{
+		"id":          "greeting",
+		"translation": "Bienvenue chez Acme !",
+	})
+	r.NoError(err)
+	transl.WithOverlay("acme", goi18nlanguage.Parse("fr-fr")[0], tr)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.String(transl.Translate(c, "greeting")+"|"+transl.Translate(c, "test-format", map[string]interface{}{"Name": "Mark"})))
+	})
+
+	w := httptest.New(app)
+	w.Cookies = "lang=fr-fr"
+	res := w.HTML("/?tenant=acme").Get()
+	r.Equal("Bienvenue chez Acme !|Bonjour Mark !", res.Body.String())
+	w.Cookies = "lang=fr-fr"
+	res = w.HTML("/?tenant=other").Get()
+	r.Equal("Bonjour à tous !|Bonjour Mark !", res.Body.String())
+
+	// the overlay has no English messages
+	w.Cookies = "lang=en-US"
+	res = w.HTML("/?tenant=acme").Get()
+	r.Equal("Hello, World!|Hello Mark!", res.Body.String())
+}
+
+func Test_i18n_DryRun(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	r.NoError(transl.DryRun("en-us", "test-format", map[string]interface{}{"Name": "Mark"}))
+	r.NoError(transl.DryRun("fr-fr", "greeting", nil))
+	r.NoError(transl.DryRun("en-us", "greeting-plural", nil))
+
+	err = transl.DryRun("en-us", "test-format", nil)
+	r.Error(err)
+	r.Contains(err.Error(), `"Name"`)
+	err = transl.DryRun("fr-fr", "test-format-loop", map[string]interface{}{"FirstName": "Mark"})
+	r.Error(err)
+	r.Contains(err.Error(), `"LastName"`)
+	r.Error(transl.DryRun("en-us", "not-a-message", nil))
+}
+
+type localizedError struct {
+	id   string
+	data interface{}
+}
+
+func (e localizedError) Error() string             { return "localized error " + e.id }
+func (e localizedError) TranslationID() string     { return e.id }
+func (e localizedError) TemplateData() interface{} { return e.data }
+
+func Test_i18n_TranslateError(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		var results []string
+		for _, err := range []error{
+			localizedError{id: "test-format", data: map[string]interface{}{"Name": "Mark"}},
+			fmt.Errorf("wrapped: %w", localizedError{id: "greeting"}),
+			localizedError{id: "not-a-message"},
+			errors.New("plain"),
+			nil,
+		} {
+			s, terr := transl.TranslateError(c, err)
+			results = append(results, fmt.Sprintf("%s (%t)", s, terr == nil))
+		}
+		return c.Render(200, render.String(strings.Join(results, "|")))
+	})
+
+	w := httptest.New(app)
+	w.Cookies = "lang=fr-fr"
+	res := w.HTML("/").Get()
+	r.Equal("Bonjour Mark ! (true)|Bonjour à tous ! (true)|localized error not-a-message (false)|plain (false)| (true)", res.Body.String())
+}
+
+func Test_i18n_TranslateChecked_NoMiddleware(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.GET("/", func(c buffalo.Context) error {
+		var errs []string
+		_, err := transl.TranslateError(c, localizedError{id: "greeting"})
+		errs = append(errs, fmt.Sprint(err != nil))
+		_, err = transl.TranslateEnum(c, "enum.status", orderStatus(1))
+		errs = append(errs, fmt.Sprint(err != nil))
+		_, err = transl.TranslateMarkdown(c, "greeting")
+		errs = append(errs, fmt.Sprint(err != nil))
+		return c.Render(200, render.String(strings.Join(errs, "|")))
+	})
+
+	w := httptest.New(app)
+	res := w.HTML("/").Get()
+	r.Equal(200, res.Code)
+	r.Equal("true|true|true", res.Body.String())
+}
+
+func Test_i18n_Watch(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"watch.en-us.yaml": {Data: []byte("- id: watch-title\n  translation: Draft\n")},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+
+	trigger := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- transl.Watch(context.Background(), trigger)
+	}()
+
+	fsys["watch.en-us.yaml"] = &fstest.MapFile{Data: []byte("- id: watch-title\n  translation: Published\n")}
+	trigger <- struct{}{}
+	close(trigger)
+	r.NoError(<-done)
+	s, err := transl.TranslateWithLang("en-us", "watch-title")
+	r.NoError(err)
+	r.Equal("Published", s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		done <- transl.Watch(ctx, make(chan struct{}))
+	}()
+	cancel()
+	r.Equal(context.Canceled, <-done)
+}
+
+func Test_i18n_ReloadInvalidatesTfuncs(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"gen.es-mx.yaml": {Data: []byte("- id: gen-title\n  translation: Borrador\n"), ModTime: time.Now().Add(-time.Hour)},
+	}
+	transl, err := i18n.NewLazy(fsys, "en-US")
+	r.NoError(err)
+	transl.IncrementalReload = true
+
+	s, err := transl.TranslateWithLang("es-mx", "gen-title")
+	r.NoError(err)
+	r.Equal("Borrador", s)
+
+	// reload while the translation function of es-MX is cached
+	fsys["gen.es-mx.yaml"] = &fstest.MapFile{Data: []byte("- id: gen-title\n  translation: Publicado\n"), ModTime: time.Now()}
+	trigger := make(chan struct{}, 1)
+	trigger <- struct{}{}
+	close(trigger)
+	r.NoError(transl.Watch(context.Background(), trigger))
+
+	s, err = transl.TranslateWithLang("es-mx", "gen-title")
+	r.NoError(err)
+	r.Equal("Publicado", s)
+}
+
+func Test_i18n_RouteParamLanguageExtractor(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	transl.LanguageExtractors = []i18n.LanguageExtractor{i18n.RouteParamLanguageExtractor}
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/{locale}/products", func(c buffalo.Context) error {
+		return c.Render(200, render.JSON(c.Value("languages")))
+	})
+
+	w := httptest.New(app)
+	res := w.HTML("/fr-FR/products").Get()
+	r.Equal(`["fr-FR","en-US"]`, strings.TrimSpace(res.Body.String()))
+	res = w.HTML("/not_a_language/products").Get()
+	r.Equal(`["en-US"]`, strings.TrimSpace(res.Body.String()))
+}
+
+func Test_i18n_LoadConflictPolicy(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"en/a.en-us.yaml": {Data: []byte("- id: conflict-title\n  translation: From a\n- id: conflict-a\n  translation: A\n")},
+		"en/b.en-us.yaml": {Data: []byte("- id: conflict-title\n  translation: From b\n")},
+	}
+
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+	s, err := transl.TranslateWithLang("en-us", "conflict-title")
+	r.NoError(err)
+	r.Equal("From b", s)
+
+	transl.ConflictPolicy = i18n.FirstWins
+	r.NoError(transl.Load())
+	s, err = transl.TranslateWithLang("en-us", "conflict-title")
+	r.NoError(err)
+	r.Equal("From a", s)
+	r.Equal([]i18n.ExportedMessage{
+		{ID: "conflict-a", Translation: "A"},
+		{ID: "conflict-title", Translation: "From a"},
+	}, transl.ExportMessages("en-us"))
+
+	transl.ConflictPolicy = i18n.ErrorOnConflict
+	err = transl.Load()
+	r.Error(err)
+	r.Equal(`i18n: message "conflict-title" is defined by both en/a.en-us.yaml and en/b.en-us.yaml`, err.Error())
+	s, err = transl.TranslateWithLang("en-us", "conflict-title")
+	r.NoError(err)
+	r.Equal("From a", s)
+}
+
+func Test_i18n_SwitchLanguageHandler(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/language", transl.SwitchLanguageHandler())
+
+	w := httptest.New(app)
+	res := w.HTML("/language?lang=fr&redirect=/products?page=2").Get()
+	r.Equal(http.StatusSeeOther, res.Code)
+	r.Equal("/products?page=2", res.Header().Get("Location"))
+	r.Contains(res.Header().Get("Set-Cookie"), "lang=fr;")
+
+	for _, redirect := range []string{"", "//evil.com/page", "https://evil.com", "/\\evil.com", "page"} {
+		res = w.HTML("/language?lang=fr-FR&redirect=%s", url.QueryEscape(redirect)).Get()
+		r.Equal(http.StatusSeeOther, res.Code, redirect)
+		r.Equal("/", res.Header().Get("Location"), redirect)
+	}
+
+	res = w.HTML("/language?lang=fr-ca").Get()
+	r.Equal(http.StatusSeeOther, res.Code)
+	r.Contains(res.Header().Get("Set-Cookie"), "lang=fr-CA;")
+
+	for _, lang := range []string{"xx", "fr-zz-totally-junk-value", "fr-<script>"} {
+		res = w.HTML("/language?lang=%s&redirect=/page", url.QueryEscape(lang)).Get()
+		r.Equal(http.StatusBadRequest, res.Code, lang)
+		r.Empty(res.Header().Get("Set-Cookie"), lang)
+	}
+}
+
+func Test_i18n_TranslateMarkdown(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	r.NoError(transl.LoadMap(map[string]map[string]string{
+		"en-us": {"md-intro": "Hello **{{.Name}}**, read the [docs](/docs)"},
+	}))
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		h, err := transl.TranslateMarkdown(c, "md-intro", map[string]interface{}{"Name": "<script>alert(1)</script>"})
+		if err != nil {
+			return err
+		}
+		_, err = transl.TranslateMarkdown(c, "not-a-message")
+		return c.Render(200, render.String(string(h)+fmt.Sprint(err != nil)))
+	})
+
+	w := httptest.New(app)
+	res := w.HTML("/").Get()
+	r.Equal("<p>Hello <strong></strong>, read the <a href=\"/docs\" rel=\"nofollow\">docs</a></p>\ntrue", res.Body.String())
+}
+
+func Test_SortLocalized(t *testing.T) {
+	r := require.New(t)
+
+	strs := []string{"Zebra", "Äpfel", "apfel", "Ödland"}
+	i18n.SortLocalized("de-DE", strs)
+	r.Equal([]string{"apfel", "Äpfel", "Ödland", "Zebra"}, strs)
+
+	strs = []string{"ö", "z", "å", "a", "ä"}
+	i18n.SortLocalized("sv", strs)
+	r.Equal([]string{"a", "z", "å", "ä", "ö"}, strs)
+
+	strs = []string{"b", "é", "a"}
+	i18n.SortLocalized("not a language", strs)
+	r.Equal([]string{"a", "b", "é"}, strs)
+}
+
+func Test_i18n_Validate(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	r.NoError(transl.Validate())
+
+	delete(transl.LanguageExtractorOptions, "CookieName")
+	transl.LanguageExtractorOptions["CookieNam"] = "lang"
+	transl.LanguageExtractorOptions["Tenant"] = "acme"
+	err = transl.Validate()
+	r.Error(err)
+	r.Equal(`i18n: unknown LanguageExtractorOptions "CookieNam", "Tenant"; i18n.CookieLanguageExtractor needs the "CookieName" option`, err.Error())
+
+	transl.LanguageExtractorOptions["CookieName"] = "lang"
+	delete(transl.LanguageExtractorOptions, "CookieNam")
+	transl.ExtractorOptionKeys = []string{"Tenant"}
+	r.NoError(transl.Validate())
+}
+
+func Test_i18n_TranslateDefaultLanguage(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "fr-FR")
+	r.NoError(err)
+
+	s, err := transl.TranslateDefaultLanguage("test-format", map[string]interface{}{"Name": "Mark"})
+	r.NoError(err)
+	r.Equal("Bonjour Mark !", s)
+
+	s, err = transl.TranslateDefaultLanguage("not-a-message")
+	r.Error(err)
+	r.Equal("not-a-message", s)
+}
+
+func Test_i18n_TrackUsage(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"usage.en-us.yaml": {Data: []byte("- id: usage-a\n  translation: A\n- id: usage-b\n  translation: B\n- id: usage-c\n  translation: C\n")},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+	r.Equal([]string{"usage-a", "usage-b", "usage-c"}, transl.ListMessageIDs())
+
+	_, err = transl.TranslateWithLang("en-us", "usage-a")
+	r.NoError(err)
+	r.Empty(transl.UsedKeys())
+
+	transl.TrackUsage = true
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.String(transl.Translate(c, "usage-c")+transl.Translate(c, "usage-x")))
+	})
+	w := httptest.New(app)
+	r.Equal("Cusage-x", w.HTML("/").Get().Body.String())
+	_, err = transl.TranslateWithLang("en-us", "usage-c")
+	r.NoError(err)
+	r.Equal([]string{"usage-c", "usage-x"}, transl.UsedKeys())
+}
+
+func Test_i18n_LocalizeNumbers(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"numbers.en-us.yaml": {Data: []byte(`
+- id: numbers-items
+  translation:
+    one: "{{.Count}} item for {{.Price}}"
+    other: "{{.Count}} items for {{.Price}}"
+`)},
+		"numbers.fr-fr.yaml": {Data: []byte(`
+- id: numbers-items
+  translation:
+    one: "{{.Count}} article pour {{.Price}}"
+    other: "{{.Count}} articles pour {{.Price}}"
+`)},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+	transl.LocalizeNumbers = true
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.String(transl.Translate(c, "numbers-items", 1234, map[string]interface{}{"Price": 5678.5})+"|"+
+			transl.Translate(c, "numbers-items", map[string]interface{}{"Count": 1, "Price": 2})))
+	})
+
+	w := httptest.New(app)
+	w.Cookies = "lang=fr-fr"
+	r.Equal("1\u00a0234 articles pour 5\u00a0678,5|1 article pour 2", w.HTML("/").Get().Body.String())
+	w.Cookies = "lang=en-US"
+	r.Equal("1,234 items for 5,678.5|1 item for 2", w.HTML("/").Get().Body.String())
+}
+
+func Test_i18n_ReloadIfChanged(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"ops.en-us.yaml": {Data: []byte("- id: ops-title\n  translation: Before\n"), ModTime: time.Now().Add(-time.Hour)},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+
+	reloaded, err := transl.ReloadIfChanged()
+	r.NoError(err)
+	r.False(reloaded)
+
+	fsys["ops.en-us.yaml"] = &fstest.MapFile{Data: []byte("- id: ops-title\n  translation: After\n"), ModTime: time.Now().Add(-time.Minute)}
+	reloaded, err = transl.ReloadIfChanged()
+	r.NoError(err)
+	r.True(reloaded)
+	s, err := transl.TranslateWithLang("en-us", "ops-title")
+	r.NoError(err)
+	r.Equal("After", s)
+
+	reloaded, err = transl.ReloadIfChanged()
+	r.NoError(err)
+	r.False(reloaded)
+}
+
+func Test_i18n_TlangHelper(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.New(render.Options{}).String(`<%= t("greeting") %>|<%= tlang("fr-fr", "test-format", {Name: "Mark"}) %>`))
+	})
+	w := httptest.New(app)
+	r.Equal("Hello, World!|Bonjour Mark !", w.HTML("/").Get().Body.String())
+}
+
+func Test_i18n_PreferDefault(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "fr-FR")
+	r.NoError(err)
+	transl.PreferDefault = true
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/languages", func(c buffalo.Context) error {
+		return c.Render(200, render.JSON(c.Value("languages")))
+	})
+
+	w := httptest.New(app)
+	req := w.HTML("/languages")
+	req.Headers["Accept-Language"] = "en-US, fr-FR;q=0.5, de;q=0.2"
+	res := req.Get()
+	r.Equal(`["fr-FR","en-US","de"]`, strings.TrimSpace(res.Body.String()))
+}
+
+func Test_Locale(t *testing.T) {
+	r := require.New(t)
+
+	l, err := i18n.ParseLocale("fr-CA")
+	r.NoError(err)
+	r.Equal("fr-CA", l.String())
+	r.Equal("fr", l.Base())
+	r.Equal("CA", l.Region())
+	r.Equal("ltr", l.Direction())
+	r.Equal("français canadien", l.DisplayName())
+
+	l, err = i18n.ParseLocale("he")
+	r.NoError(err)
+	r.Equal("he", l.Base())
+	r.Equal("", l.Region())
+	r.Equal("rtl", l.Direction())
+	r.Equal("עברית", l.DisplayName())
+
+	l, err = i18n.ParseLocale("ar-EG")
+	r.NoError(err)
+	r.Equal("rtl", l.Direction())
+
+	_, err = i18n.ParseLocale("not a tag")
+	r.Error(err)
+
+	app := buffalo.New(buffalo.Options{})
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		l := i18n.ContextLocale(c)
+		return c.Render(200, render.String(l.String()+" "+l.Direction()))
+	})
+
+	w := httptest.New(app)
+	req := w.HTML("/")
+	req.Headers["Accept-Language"] = "fr-FR"
+	res := req.Get()
+	r.Equal("fr-FR ltr", res.Body.String())
+}
+
+func Test_i18n_Includes(t *testing.T) {
+	r := require.New(t)
+
+	old := time.Now().Add(-time.Hour)
+	fsys := fstest.MapFS{
+		"app/_common.yaml":     {Data: []byte("include-ok: {other: OK}\ninclude-cancel: {other: Cancel}\n"), ModTime: old},
+		"app/shared/_nav.json": {Data: []byte(`[{"id": "include-home", "translation": "Home"}]`), ModTime: old},
+		"app/all.en-us.yaml":   {Data: []byte("_include: [common.yaml, shared/nav.json]\ninclude-cancel: {other: Dismiss}\n"), ModTime: old},
+		"app/all.de.yaml":      {Data: []byte("- _include: common.yaml\n- id: include-title\n  translation: Titel\n"), ModTime: old},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+	tr := func(lang, id string) string {
+		s, err := transl.TranslateWithLang(lang, id)
+		r.NoError(err)
+		return s
+	}
+
+	r.Equal("OK", tr("en-US", "include-ok"))
+	r.Equal("Dismiss", tr("en-US", "include-cancel"))
+	r.Equal("Home", tr("en-US", "include-home"))
+	r.Equal("OK", tr("de", "include-ok"))
+	r.Equal("Titel", tr("de", "include-title"))
+
+	// a change of the included file reloads the files including it
+	transl.IncrementalReload = true
+	fsys["app/_common.yaml"] = &fstest.MapFile{Data: []byte("include-ok: {other: Okay}\n"), ModTime: time.Now().Add(-time.Minute)}
+	changed, err := transl.ReloadIfChanged()
+	r.NoError(err)
+	r.True(changed)
+	r.Equal("Okay", tr("de", "include-ok"))
+
+	fsys["app/all.en-us.yaml"] = &fstest.MapFile{Data: []byte("_include: missing.yaml\n"), ModTime: time.Now().Add(-time.Minute)}
+	_, err = transl.ReloadIfChanged()
+	r.Error(err)
+	r.Contains(err.Error(), "missing.yaml")
+
+	fsys = fstest.MapFS{
+		"_loop.yaml":      {Data: []byte("_include: loop.yaml\nloop: {other: Loop}\n")},
+		"loop.en-us.yaml": {Data: []byte("_include: loop.yaml\n")},
+	}
+	_, err = i18n.New(fsys, "en-US")
+	r.Error(err)
+}
+
+func Test_i18n_MessageSource(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"src/_common.yaml":        {Data: []byte("- id: source-ok\n  translation: OK\n")},
+		"src/home.en-us.yaml":     {Data: []byte("- _include: common.yaml\n- id: source-home\n  translation: Home\n")},
+		"src/settings.en-us.yaml": {Data: []byte("- id: source-settings\n  translation: Settings\n")},
+		"src/settings.sv.yaml":    {Data: []byte("- id: source-settings\n  translation: Inställningar\n")},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+
+	src := func(lang, id string) string {
+		path, ok := transl.MessageSource(lang, id)
+		r.True(ok, id)
+		return path
+	}
+	r.Equal("src/home.en-us.yaml", src("en-US", "source-home"))
+	r.Equal("src/_common.yaml", src("en-US", "source-ok"))
+	r.Equal("src/settings.en-us.yaml", src("en-us", "source-settings"))
+	r.Equal("src/settings.sv.yaml", src("sv", "source-settings"))
+
+	r.NoError(transl.AddFile("uploads/home.sv.yaml", []byte("- id: source-home\n  translation: Hem\n")))
+	r.Equal("uploads/home.sv.yaml", src("sv", "source-home"))
+
+	tr, err := translation.NewTranslation(map[string]interface{}{"id": "source-added", "translation": "Tillagd"})
+	r.NoError(err)
+	transl.AddTranslation(goi18nlanguage.Parse("sv")[0], tr)
+	_, ok := transl.MessageSource("sv", "source-added")
+	r.False(ok)
+	_, ok = transl.MessageSource("sv", "source-missing")
+	r.False(ok)
+	_, ok = transl.MessageSource("not a language", "source-home")
+	r.False(ok)
+}
+
+func Test_i18n_LoadContext(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"ctx.en-us.yaml": {Data: []byte("- id: ctx-title\n  translation: Before\n")},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+
+	fsys["ctx.en-us.yaml"] = &fstest.MapFile{Data: []byte("- id: ctx-title\n  translation: After\n")}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.Equal(context.Canceled, transl.LoadContext(ctx))
+	s, err := transl.TranslateWithLang("en-US", "ctx-title")
+	r.NoError(err)
+	r.Equal("Before", s)
+
+	r.NoError(transl.LoadContext(context.Background()))
+	s, err = transl.TranslateWithLang("en-US", "ctx-title")
+	r.NoError(err)
+	r.Equal("After", s)
+}
+
+func Test_i18n_TranslatePluralCategory(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(fstest.MapFS{
+		"forced.en-us.yaml": {Data: []byte(`
+- id: forced-seats
+  translation:
+    one: "Only {{.Count}} seat left"
+    other: "{{.Count}} seats available"
+`)},
+	}, "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		data := map[string]interface{}{"Count": 1}
+		s := []string{
+			transl.TranslatePluralCategory(c, "forced-seats", "other", data),
+			transl.TranslatePluralCategory(c, "forced-seats", "one", data),
+			transl.TranslatePluralCategory(c, "forced-seats", "few", data),
+			transl.TranslatePluralCategory(c, "forced-seats", "plenty", data),
+		}
+		return c.Render(200, render.String(strings.Join(s, "|")))
+	})
+	w := httptest.New(app)
+	r.Equal("1 seats available|Only 1 seat left|forced-seats|forced-seats", w.HTML("/").Get().Body.String())
+}
+
+func Test_i18n_CompleteLanguages(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(fstest.MapFS{
+		"complete.en-gb.yaml": {Data: []byte("- id: complete-a\n  translation: A\n- id: complete-b\n  translation: B\n- id: complete-c\n  translation: C\n- id: complete-d\n  translation: D\n- id: complete-e\n  translation: E\n")},
+		"complete.da.yaml":    {Data: []byte("- id: complete-a\n  translation: A\n- id: complete-b\n  translation: B\n- id: complete-c\n  translation: C\n- id: complete-d\n  translation: D\n")},
+		"complete.fi.yaml":    {Data: []byte("- id: complete-a\n  translation: A\n- id: complete-extra\n  translation: X\n")},
+	}, "en-GB")
+	r.NoError(err)
+
+	r.Equal(1.0, transl.Completeness("en-GB"))
+	r.Equal(0.8, transl.Completeness("da"))
+	r.Equal(0.2, transl.Completeness("fi"))
+	r.Equal(0.0, transl.Completeness("not a language"))
+
+	langs := transl.CompleteLanguages(0.8)
+	r.Contains(langs, "en-gb")
+	r.Contains(langs, "da")
+	r.NotContains(langs, "fi")
+	r.Contains(transl.CompleteLanguages(0.2), "fi")
+}
+
+func Test_i18n_ExtendedAcceptLanguage(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	req := nethttptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Language", "x-klingon, fr-FR-u-ca-gregory-nu-latn;q=0.9, de-CH-x-private;q=0.8")
+	r.Equal([]string{"fr-FR", "de-CH", "en-US"}, transl.NegotiateRequest(req))
+
+	req.Header.Set("Accept-Language", "ja-JP-u-ca-japanese-x-lvariant-JP, en-US-u-ca-gregory;q=0.5")
+	r.Equal([]string{"ja-JP", "en-US"}, transl.NegotiateRequest(req))
+
+	w := httptest.New(app())
+	hreq := w.HTML("/")
+	hreq.Headers["Accept-Language"] = "fr-FR-u-ca-gregory"
+	r.Equal("Bonjour à tous !", strings.TrimSpace(hreq.Get().Body.String()))
+}
+
+// nilLoggerContext is a custom context without logger.
+type nilLoggerContext struct {
+	buffalo.Context
+}
+
+func (nilLoggerContext) Logger() buffalo.Logger {
+	return nil
+}
+
+func Test_i18n_SilentMode(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	transl.LanguageExtractorOptions["CookieName"] = ""
+	transl.SilentMode = true
+
+	out := &bytes.Buffer{}
+	l := logrus.New()
+	l.SetOutput(out)
+	l.SetLevel(logrus.DebugLevel)
+	app := buffalo.New(buffalo.Options{Logger: logger.Logrus{FieldLogger: l}})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		// a context without logger doesn't panic
+		i18n.CookieLanguageExtractor(i18n.LanguageExtractorOptions{"CookieName": ""}, nilLoggerContext{c})
+		return c.Render(200, render.String(transl.Translate(c, "greeting")))
+	})
+	w := httptest.New(app)
+
+	r.Equal("Hello, World!", w.HTML("/").Get().Body.String())
+	r.NotContains(out.String(), "i18n middleware")
+}
+
+func Test_i18n_BidiIsolates(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"bidi.en-us.yaml": {Data: []byte("- id: bidi-welcome\n  translation: \"Welcome {{.Name}}\"\n")},
+		"bidi.ar.yaml":    {Data: []byte("- id: bidi-welcome\n  translation: \"مرحبا {{.Name}}\"\n")},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+	transl.BidiIsolates = true
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.String(transl.Translate(c, "bidi-welcome", map[string]interface{}{"Name": "Mark"})))
+	})
+
+	w := httptest.New(app)
+	w.Cookies = "lang=ar"
+	r.Equal("مرحبا \u2066Mark\u2069", w.HTML("/").Get().Body.String())
+	w.Cookies = "lang=en-US"
+	r.Equal("Welcome Mark", w.HTML("/").Get().Body.String())
+}
+
+// cartSummary is template data holding the plural count of a message.
+type cartSummary struct {
+	Count int
+	Owner string
+}
+
+func Test_i18n_TranslateDataCount(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(fstest.MapFS{
+		"datacount.en-us.yaml": {Data: []byte(`
+- id: datacount-cart
+  translation:
+    one: "{{.Owner}} has {{.Count}} item"
+    other: "{{.Owner}} has {{.Count}} items"
+`)},
+	}, "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		s := []string{
+			transl.Translate(c, "datacount-cart", cartSummary{Count: 1, Owner: "Mark"}),
+			transl.Translate(c, "datacount-cart", &cartSummary{Count: 3, Owner: "Mark"}),
+			transl.Translate(c, "datacount-cart", map[string]interface{}{"Count": 1, "Owner": "Ann"}),
+			transl.Translate(c, "datacount-cart", map[string]interface{}{"Count": uint(2), "Owner": "Ann"}),
+			transl.Translate(c, "datacount-cart", map[string]interface{}{"Count": 1.0, "Owner": "Ann"}),
+		}
+		return c.Render(200, render.String(strings.Join(s, "|")))
+	})
+	w := httptest.New(app)
+	r.Equal("Mark has 1 item|Mark has 3 items|Ann has 1 item|Ann has 2 items|Ann has 1 item", w.HTML("/").Get().Body.String())
+
+	transl.GlobalTemplateData = map[string]interface{}{"Site": "Acme"}
+	transl.LocalizeNumbers = true
+	r.Equal("Mark has 1 item|Mark has 3 items|Ann has 1 item|Ann has 2 items|Ann has 1 item", w.HTML("/").Get().Body.String())
+}
+
+// orderStatus is an enum-like type, see TranslateEnum.
+type orderStatus int
+
+func (s orderStatus) String() string {
+	return [...]string{"pending", "shipped", "lost"}[s]
+}
+
+func Test_i18n_TranslateEnum(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(fstest.MapFS{
+		"enum.en-us.yaml": {Data: []byte("- id: enum.status.pending\n  translation: Pending\n- id: enum.status.shipped\n  translation: Shipped\n")},
+	}, "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		shipped, err := transl.TranslateEnum(c, "enum.status", orderStatus(1))
+		if err != nil {
+			return err
+		}
+		_, err = transl.TranslateEnum(c, "enum.status", orderStatus(2))
+		_, nilErr := transl.TranslateEnum(c, "enum.status", nil)
+		_, nilPtrErr := transl.TranslateEnum(c, "enum.status", (*orderStatus)(nil))
+		return c.Render(200, render.String(shipped+"|"+err.Error()+"|"+nilErr.Error()+"|"+nilPtrErr.Error()))
+	})
+	w := httptest.New(app)
+	r.Equal(`Shipped|i18n: no translation found for "enum.status.lost"|i18n: no enum.status value to translate|i18n: no enum.status value to translate`, w.HTML("/").Get().Body.String())
+}
+
+func Test_i18n_RenderFilter(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(fstest.MapFS{
+		"filter.en-us.yaml": {Data: []byte("- id: filter-greeting\n  translation: \"Hello {{.Title}} {{.Name}} (informal)\"\n")},
+	}, "en-US")
+	r.NoError(err)
+	transl.RenderFilter = func(s string) string {
+		s = strings.Replace(s, " (informal)", "", -1)
+		return strings.Join(strings.Fields(s), " ")
+	}
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.String(transl.Translate(c, "filter-greeting", map[string]interface{}{"Title": "", "Name": "Mark"})+"|"+
+			transl.Translate(c, "filter-missing  id")))
+	})
+	w := httptest.New(app)
+	r.Equal("Hello Mark|filter-missing  id", w.HTML("/").Get().Body.String())
+}
+
+func Test_i18n_TranslateDomain(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"shared.en-us.yaml":      {Data: []byte("- id: domain-welcome\n  translation: Welcome!\n- id: domain-bye\n  translation: Bye!\n")},
+		"emails/mail.en-us.yaml": {Data: []byte("- id: domain-welcome\n  translation: Welcome to Acme, {{.Name}}\n")},
+		"emails/fr-fr/mail.yaml": {Data: []byte("- id: domain-welcome\n  translation: Bienvenue chez Acme, {{.Name}}\n")},
+		"web/other.en-us.yaml":   {Data: []byte("- id: domain-other\n  translation: Not a domain\n")},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+	transl.Domains = []string{"emails"}
+	r.NoError(transl.Load())
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		data := map[string]interface{}{"Name": "Mark"}
+		s := []string{
+			transl.TranslateDomain(c, "emails", "domain-welcome", data),
+			transl.TranslateDomain(c, "emails", "domain-bye"),
+			transl.Translate(c, "domain-welcome"),
+			transl.Translate(c, "domain-other"),
+		}
+		return c.Render(200, render.String(strings.Join(s, "|")))
+	})
+	w := httptest.New(app)
+	r.Equal("Welcome to Acme, Mark|Bye!|Welcome!|Not a domain", w.HTML("/").Get().Body.String())
+	w.Cookies = "lang=fr-fr"
+	r.True(strings.HasPrefix(w.HTML("/").Get().Body.String(), "Bienvenue chez Acme, Mark|domain-bye|"))
+}
+
+func Test_i18n_CanonicalLanguages(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/languages", func(c buffalo.Context) error {
+		return c.Render(200, render.JSON(c.Value("languages")))
+	})
+	w := httptest.New(app)
+
+	get := func() string {
+		w.Cookies = "lang=english"
+		req := w.HTML("/languages")
+		req.Headers["Accept-Language"] = "fr-fr, fr-FR;q=0.9, de-ch;q=0.8, en-us;q=0.5"
+		return strings.TrimSpace(req.Get().Body.String())
+	}
+	r.Equal(`["english","fr-fr","de-ch","en-us"]`, get())
+
+	transl.CanonicalLanguages = true
+	r.Equal(`["fr-FR","de-CH","en-US"]`, get())
+}
+
+func Test_FormatRelative(t *testing.T) {
+	r := require.New(t)
+
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		lang string
+		d    time.Duration
+		want string
+	}{
+		{"en-US", -10 * time.Second, "now"},
+		{"en-US", -time.Minute, "1 minute ago"},
+		{"en-US", 2 * time.Hour, "in 2 hours"},
+		{"en-US", -24 * time.Hour, "yesterday"},
+		{"en-US", 24 * time.Hour, "tomorrow"},
+		{"en-US", -3 * 24 * time.Hour, "3 days ago"},
+		{"en-US", -14 * 24 * time.Hour, "2 weeks ago"},
+		{"en-US", 60 * 24 * time.Hour, "in 2 months"},
+		{"en-US", -800 * 24 * time.Hour, "2 years ago"},
+		{"fr-CA", -3 * 24 * time.Hour, "il y a 3 jours"},
+		{"fr", 90 * time.Minute, "dans 2 heures"},
+		{"de", -3 * 24 * time.Hour, "vor 3 Tagen"},
+		{"de-AT", 365 * 24 * time.Hour, "in 1 Jahr"},
+		{"es", -24 * time.Hour, "ayer"},
+		{"es-MX", 5 * time.Minute, "dentro de 5 minutos"},
+		{"ja", -3 * time.Hour, "3 hours ago"},
+		{"not a language", -3 * time.Hour, "3 hours ago"},
+	} {
+		r.Equal(tt.want, i18n.FormatRelative(tt.lang, now.Add(tt.d), now), "%s %s", tt.lang, tt.d)
+	}
+
+	// beyond the range of a time.Duration
+	r.Equal("2025 years ago", i18n.FormatRelative("en-US", time.Time{}, now))
+	r.Equal("300 years ago", i18n.FormatRelative("en-US", now.AddDate(-300, 0, 0), now))
+	r.Equal("in 300 years", i18n.FormatRelative("en-US", now.AddDate(300, 0, 0), now))
+}
+
+func Test_i18n_IsSupported(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	for _, lang := range []string{"en-US", "en", "en-GB", "fr-fr", "fr-CA", "FR"} {
+		r.True(transl.IsSupported(lang), lang)
+	}
+	for _, lang := range []string{"", " ", "xx", "junk value", "<script>", "zz-ZZ", "fr-<script>", "fr-zzzzzzzzzzzzzzzzzzzz", "fr-zz-totally-junk-value"} {
+		r.False(transl.IsSupported(lang), lang)
+	}
+}
+
+func Test_i18n_PluralPreview(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(fstest.MapFS{
+		"preview.en-us.yaml": {Data: []byte(`
+- id: preview-files
+  translation:
+    one: "{{.Count}} file"
+    other: "{{.Count}} files"
+- id: preview-title
+  translation: "Files"
+`)},
+		"preview.pl.yaml": {Data: []byte(`
+- id: preview-files
+  translation:
+    one: "{{.Count}} plik"
+    few: "{{.Count}} pliki"
+    many: "{{.Count}} plików"
+    other: "{{.Count}} pliku"
+`)},
+	}, "en-US")
+	r.NoError(err)
+
+	preview, err := transl.PluralPreview("en-US", "preview-files")
+	r.NoError(err)
+	r.Equal(map[string]string{"one": "1 file", "other": "0 files"}, preview)
+
+	preview, err = transl.PluralPreview("pl", "preview-files")
+	r.NoError(err)
+	r.Equal(map[string]string{"one": "1 plik", "few": "2 pliki", "many": "0 plików", "other": "0.5 pliku"}, preview)
+
+	preview, err = transl.PluralPreview("en-US", "preview-title")
+	r.NoError(err)
+	r.Equal(map[string]string{"other": "Files"}, preview)
+
+	_, err = transl.PluralPreview("en-US", "preview-missing")
+	r.Error(err)
+	_, err = transl.PluralPreview("not a language", "preview-files")
+	r.Error(err)
+}
+
+func Test_i18n_LenientReload(t *testing.T) {
+	r := require.New(t)
+
+	fsys := fstest.MapFS{
+		"lenient.en-us.yaml": {Data: []byte("- id: lenient-title\n  translation: Before\n"), ModTime: time.Now().Add(-time.Hour)},
+	}
+	transl, err := i18n.New(fsys, "en-US")
+	r.NoError(err)
+	transl.SilentMode = true
+
+	app := buffalo.New(buffalo.Options{Env: "development"})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.String(transl.Translate(c, "lenient-title")))
+	})
+	w := httptest.New(app)
+
+	// saved half-written
+	transl.LenientReload = true
+	fsys["lenient.en-us.yaml"] = &fstest.MapFile{Data: []byte("- id: lenient-title\n  translation: [Aft\n"), ModTime: time.Now().Add(-time.Minute)}
+	res := w.HTML("/").Get()
+	r.Equal(200, res.Code)
+	r.Equal("Before", res.Body.String())
+	r.Len(transl.ExportMessages("en-US"), 1)
+
+	transl.IncrementalReload = true
+	fsys["lenient.en-us.yaml"] = &fstest.MapFile{Data: []byte("- id: lenient-title\n  translation: [Af\n"), ModTime: time.Now().Add(-time.Second)}
+	res = w.HTML("/").Get()
+	r.Equal(200, res.Code)
+	r.Equal("Before", res.Body.String())
+	r.Len(transl.ExportMessages("en-US"), 1)
+
+	fsys["lenient.en-us.yaml"] = &fstest.MapFile{Data: []byte("- id: lenient-title\n  translation: After\n"), ModTime: time.Now().Add(-2 * time.Second)}
+	r.Equal("After", w.HTML("/").Get().Body.String())
+
+	transl.LenientReload = false
+	fsys["lenient.en-us.yaml"] = &fstest.MapFile{Data: []byte("- id: lenient-title\n  translation: [\n"), ModTime: time.Now().Add(-3 * time.Second)}
+	r.Equal(500, w.HTML("/").Get().Code)
+}
+
+func Test_i18n_AddTranslation_Concurrent(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+	lang := goi18nlanguage.Parse("en-US")[0]
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				tr, err := translation.NewTranslation(map[string]interface{}{
+					"id":          fmt.Sprintf("concurrent-%d-%d", i, j),
+					"translation": fmt.Sprintf("Message %d-%d", i, j),
+				})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				transl.AddTranslation(lang, tr)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				transl.TranslateWithLang("en-US", "greeting")
+				transl.AvailableLanguages()
+				transl.ExportMessages("en-US")
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 4; i++ {
+		s, err := transl.TranslateWithLang("en-US", fmt.Sprintf("concurrent-%d-49", i))
+		r.NoError(err)
+		r.Equal(fmt.Sprintf("Message %d-49", i), s)
+	}
+}
+
+// failingWriter is an io.Writer always failing.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func Test_i18n_TranslateTo(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	r.NoError(err)
+
+	app := buffalo.New(buffalo.Options{})
+	app.Use(transl.Middleware())
+	app.GET("/", func(c buffalo.Context) error {
+		bb := &bytes.Buffer{}
+		for _, id := range []string{"greeting", "translate-to-missing"} {
+			if err := transl.TranslateTo(bb, c, id); err != nil {
+				return err
+			}
+			bb.WriteString("|")
+		}
+		for _, args := range [][]interface{}{{1}, {5}, {map[string]interface{}{"Count": 5}}} {
+			if err := transl.TranslateTo(bb, c, "greeting-plural", args...); err != nil {
+				return err
+			}
+			bb.WriteString("|")
+		}
+		if err := transl.TranslateTo(bb, c, "test-format-loop", struct{ FirstName, LastName string }{"Mark", "Bates"}); err != nil {
+			return err
+		}
+		if err := transl.TranslateTo(failingWriter{}, c, "greeting"); err == nil {
+			return errors.New("the error of the writer should be returned")
+		}
+		return c.Render(200, render.String(bb.String()))
+	})
+	w := httptest.New(app)
+	w.Cookies = "lang=fr-fr"
+	r.Equal("Bonjour à tous !|translate-to-missing|Bonjour, tout seul !|Bonjour, 5 personnes !|Bonjour, 5 personnes !|M. Mark Bates", w.HTML("/").Get().Body.String())
+
+	// without the middleware
+	app = buffalo.New(buffalo.Options{})
+	app.GET("/", func(c buffalo.Context) error {
+		return c.Render(200, render.String(fmt.Sprint(transl.TranslateTo(io.Discard, c, "greeting") != nil)))
+	})
+	r.Equal("true", httptest.New(app).HTML("/").Get().Body.String())
+}
+
+func Test_i18n_Load_KeepsAddedMessages(t *testing.T) {
+	r := require.New(t)
+
+	transl, err := i18n.New(fstest.MapFS{
+		"kept.en-us.yaml": {Data: []byte("- id: kept-file\n  translation: From the file\n")},
+	}, "en-US")
+	r.NoError(err)
+	tr, err := translation.NewTranslation(map[string]interface{}{"id": "kept-added", "translation": "Added"})
+	r.NoError(err)
+	transl.AddTranslation(goi18nlanguage.Parse("en-us")[0], tr)
+	r.NoError(transl.LoadMap(map[string]map[string]string{"en-us": {"kept-map": "From the map"}}))
+	plugin, err := i18n.New(fstest.MapFS{
+		"plugin.en-us.yaml": {Data: []byte("- id: kept-plugin\n  translation: From the plugin\n")},
+	}, "en-US")
+	r.NoError(err)
+	r.NoError(transl.Merge(plugin))
+
+	r.NoError(transl.Load())
+	ids := []string{}
+	for _, m := range transl.ExportMessages("en-us") {
+		ids = append(ids, m.ID)
+	}
+	r.Equal([]string{"kept-added", "kept-file", "kept-map", "kept-plugin"}, ids)
+	for id, want := range map[string]string{"kept-added": "Added", "kept-map": "From the map", "kept-plugin": "From the plugin"} {
+		s, err := transl.TranslateWithLang("en-us", id)
+		r.NoError(err)
+		r.Equal(want, s)
+	}
+	source, ok := transl.MessageSource("en-us", "kept-plugin")
+	r.True(ok)
+	r.Equal("plugin.en-us.yaml", source)
+}
+
+func Benchmark_AvailableLanguages(b *testing.B) {
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			transl.AvailableLanguages()
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		en := &goi18nlanguage.Language{Tag: "en-us"}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			// drop the cache, as a load does
+			b.StopTimer()
+			transl.AddTranslation(en)
+			b.StartTimer()
+			transl.AvailableLanguages()
+		}
+	})
+}
+
+// benchContext is a minimal buffalo.Context for the middleware benchmarks.
+type benchContext struct {
+	*buffalo.DefaultContext
+	res http.ResponseWriter
+}
+
+func (c benchContext) Response() http.ResponseWriter {
+	return c.res
+}
+
+func Benchmark_Middleware(b *testing.B) {
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	if err != nil {
+		b.Fatal(err)
+	}
+	h := transl.Middleware()(func(c buffalo.Context) error {
+		return nil
+	})
+	res := nethttptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := benchContext{DefaultContext: &buffalo.DefaultContext{Context: context.Background()}, res: res}
+		c.Set("env", "test")
+		c.Set("languages", []string{"fr-fr", "en-US"})
+		if err := h(c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_TranslateTo(b *testing.B) {
+	transl, err := i18n.New(os.DirFS("locales"), "en-US")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var c buffalo.Context
+	h := transl.Middleware()(func(hc buffalo.Context) error {
+		c = hc
+		return nil
+	})
+	bc := benchContext{DefaultContext: &buffalo.DefaultContext{Context: context.Background()}, res: nethttptest.NewRecorder()}
+	bc.Set("env", "test")
+	bc.Set("languages", []string{"fr-fr", "en-US"})
+	if err := h(bc); err != nil {
+		b.Fatal(err)
+	}
+	data := struct{ FirstName, LastName string }{"Mark", "Bates"}
+	bb := &bytes.Buffer{}
+
+	b.Run("Translate", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			bb.Reset()
+			bb.WriteString(transl.Translate(c, "test-format-loop", data))
+		}
+	})
+	b.Run("TranslateTo", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			bb.Reset()
+			if err := transl.TranslateTo(bb, c, "test-format-loop", data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
 func eq(a, b string) bool {
 	clean := func(s string) string {
 		s = strings.TrimSpace(strings.Replace(s, "\n", "", -1))
@@ -0,0 +1,205 @@
+package i18n
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nicksnyder/go-i18n/i18n/translation"
+	"gopkg.in/yaml.v2"
+)
+
+// includeKey is the directive of a locale file listing the files to
+// include, see Translator.Load.
+const includeKey = "_include"
+
+// isFragment tells whether the file at path is a fragment, meant to be
+// included by locale files rather than loaded on its own: its name starts
+// with an underscore, such as "_common.yaml".
+func isFragment(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), "_")
+}
+
+// parseFileIncludes parses the locale file at path, named name (see
+// localeFileName), along with the files it includes. The messages of the
+// included files come first, in the order of the directive, so the file can
+// override them.
+func (t *Translator) parseFileIncludes(path, name string, b []byte, depth int) (*localeFile, error) {
+	includes, b, err := splitIncludes(name, b)
+	if err != nil {
+		return nil, err
+	}
+	lf, err := t.parseFile(name, b)
+	if err != nil || len(includes) == 0 {
+		return lf, err
+	}
+	if depth >= maxNesting {
+		return nil, fmt.Errorf("includes are nested too deeply, is there a circular include?")
+	}
+
+	merged := &localeFile{lang: lf.lang, descriptions: map[string]string{}, origins: map[string]string{}}
+	byID := map[string]int{}
+	// add adds the messages of inc, the file included from incPath, or the
+	// including file itself if incPath is empty
+	add := func(inc *localeFile, incPath string) {
+		for _, tr := range inc.translations {
+			if origin, ok := inc.origins[tr.ID()]; ok {
+				merged.origins[tr.ID()] = origin
+			} else if incPath != "" {
+				merged.origins[tr.ID()] = incPath
+			} else {
+				delete(merged.origins, tr.ID())
+			}
+			if i, ok := byID[tr.ID()]; ok {
+				merged.translations[i] = tr
+				continue
+			}
+			byID[tr.ID()] = len(merged.translations)
+			merged.translations = append(merged.translations, tr)
+		}
+		for id, desc := range inc.descriptions {
+			merged.descriptions[id] = desc
+		}
+		merged.includes = append(merged.includes, inc.includes...)
+	}
+	for _, inc := range includes {
+		incPath, err := t.includePath(path, inc)
+		if err != nil {
+			return nil, err
+		}
+		ib, err := readLocaleFile(t.FS, incPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read included file %s: %v", incPath, err)
+		}
+		// the included file has the language of the including one
+		incName := strings.TrimSuffix(name, filepath.Ext(name)) + filepath.Ext(incPath)
+		ilf, err := t.parseFileIncludes(incPath, incName, ib, depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", incPath, err)
+		}
+		merged.includes = append(merged.includes, incPath)
+		add(ilf, incPath)
+	}
+	add(lf, "")
+	sort.Sort(translation.SortableByID(merged.translations))
+	return merged, nil
+}
+
+// includePath returns the path of the file included as inc by the locale
+// file at path, relative to its directory. The leading underscore of a
+// fragment can be omitted: "common.yaml" includes "_common.yaml" when there
+// is no "common.yaml".
+func (t *Translator) includePath(path, inc string) (string, error) {
+	p := filepath.ToSlash(filepath.Join(filepath.Dir(path), inc))
+	if !fs.ValidPath(p) {
+		return "", fmt.Errorf("invalid included file %q", inc)
+	}
+	if _, err := fs.Stat(t.FS, p); errors.Is(err, fs.ErrNotExist) && !isFragment(p) {
+		fragment := filepath.ToSlash(filepath.Join(filepath.Dir(p), "_"+filepath.Base(p)))
+		if _, err := fs.Stat(t.FS, fragment); err == nil {
+			return fragment, nil
+		}
+	}
+	return p, nil
+}
+
+// splitIncludes removes the include directive from the content of a locale
+// file, and returns the files it lists. The directive is a top-level key of
+// the flat format ("_include: [common.yaml]"), or an entry of its own in the
+// standard format ("- _include: [common.yaml]").
+func splitIncludes(filename string, b []byte) ([]string, []byte, error) {
+	if !bytes.Contains(b, []byte(includeKey)) {
+		return nil, b, nil
+	}
+	var unmarshal func([]byte, interface{}) error
+	var marshal func(interface{}) ([]byte, error)
+	switch filepath.Ext(filename) {
+	case ".json":
+		unmarshal, marshal = json.Unmarshal, json.Marshal
+	case ".yaml":
+		unmarshal, marshal = yaml.Unmarshal, yaml.Marshal
+	default:
+		return nil, b, nil
+	}
+
+	var data interface{}
+	if err := unmarshal(b, &data); err != nil {
+		// reported by go-i18n
+		return nil, b, nil
+	}
+	var includes []string
+	var err error
+	switch d := data.(type) {
+	case map[string]interface{}:
+		includes, err = includeList(d[includeKey])
+		delete(d, includeKey)
+	case map[interface{}]interface{}:
+		includes, err = includeList(d[includeKey])
+		delete(d, includeKey)
+	case []interface{}:
+		kept := make([]interface{}, 0, len(d))
+		for _, entry := range d {
+			var v interface{}
+			var ok bool
+			switch e := entry.(type) {
+			case map[string]interface{}:
+				v, ok = e[includeKey]
+			case map[interface{}]interface{}:
+				v, ok = e[includeKey]
+			}
+			if !ok {
+				kept = append(kept, entry)
+				continue
+			}
+			incs, lerr := includeList(v)
+			if lerr != nil {
+				err = lerr
+			}
+			includes = append(includes, incs...)
+		}
+		data = kept
+	}
+	if err != nil || len(includes) == 0 {
+		return nil, b, err
+	}
+	b, err = marshal(data)
+	return includes, b, err
+}
+
+// includeList returns the files listed by an include directive, a file or
+// a list of files.
+func includeList(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		files := make([]string, 0, len(v))
+		for _, f := range v {
+			s, ok := f.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s directive: %v", includeKey, v)
+			}
+			files = append(files, s)
+		}
+		return files, nil
+	}
+	return nil, fmt.Errorf("invalid %s directive: %v", includeKey, v)
+}
+
+// includesChanged tells whether one of the files included by lf is among
+// the changed ones.
+func (lf *localeFile) includesChanged(changed map[string]bool) bool {
+	for _, inc := range lf.includes {
+		if changed[inc] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package i18n
+
+import (
+	"github.com/gobuffalo/buffalo"
+	xlanguage "golang.org/x/text/language"
+	xdisplay "golang.org/x/text/language/display"
+)
+
+// Locale is a language tag, with the properties an app usually needs about
+// it, so that they aren't parsed again from a string here and there.
+type Locale struct {
+	Tag xlanguage.Tag
+}
+
+// ParseLocale parses a language tag, such as "fr-CA", into a Locale.
+func ParseLocale(tag string) (Locale, error) {
+	t, err := xlanguage.Parse(tag)
+	if err != nil {
+		return Locale{}, err
+	}
+	return Locale{Tag: t}, nil
+}
+
+// ContextLocale returns the Locale of the translations of the context, the
+// one declared in the Content-Language header, or the undetermined Locale
+// if the context has no translations.
+func ContextLocale(c buffalo.Context) Locale {
+	lang := contextLanguage(c)
+	if lang == nil {
+		return Locale{Tag: xlanguage.Und}
+	}
+	l, _ := ParseLocale(lang.Tag)
+	return l
+}
+
+// String returns the canonical form of the tag, such as "fr-CA".
+func (l Locale) String() string {
+	return l.Tag.String()
+}
+
+// Base returns the ISO 639 code of the language, without script or region:
+// "zh" for "zh-Hans-CN".
+func (l Locale) Base() string {
+	base, _ := l.Tag.Base()
+	return base.String()
+}
+
+// Region returns the ISO 3166 code of the region of the tag ("CA" for
+// "fr-CA"), or an empty string if the tag doesn't have one.
+func (l Locale) Region() string {
+	region, conf := l.Tag.Region()
+	if conf != xlanguage.Exact {
+		return ""
+	}
+	return region.String()
+}
+
+// Direction returns the direction of the script of the language, for the
+// HTML dir attribute: "rtl" for Arabic or Hebrew, "ltr" otherwise.
+func (l Locale) Direction() string {
+	script, _ := l.Tag.Script()
+	switch script.String() {
+	case "Adlm", "Arab", "Hebr", "Mand", "Nkoo", "Rohg", "Samr", "Syrc", "Thaa":
+		return "rtl"
+	}
+	return "ltr"
+}
+
+// DisplayName returns the name of the language, written in that language:
+// "français canadien" for "fr-CA". See LanguageName for the name in another
+// language.
+func (l Locale) DisplayName() string {
+	if name := xdisplay.Self.Name(l.Tag); name != "" {
+		return name
+	}
+	return l.String()
+}
@@ -0,0 +1,230 @@
+package i18n
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/nicksnyder/go-i18n/i18n/language"
+	"github.com/nicksnyder/go-i18n/i18n/translation"
+)
+
+// LocalizeConfig gives full control over a single translation, see
+// LocalizeWith.
+type LocalizeConfig struct {
+	// MessageID identifies the message to translate.
+	MessageID string
+	// TemplateData is the data (map[string]interface{} or struct) for the
+	// message template.
+	TemplateData interface{}
+	// PluralCount selects the plural form of the message. It is available
+	// as .Count in the message template.
+	PluralCount interface{}
+	// PluralCategory forces the plural form of the message ("zero", "one",
+	// "two", "few", "many" or "other"), rather than selecting it from
+	// PluralCount with the CLDR rules of the language.
+	PluralCategory string
+	// DefaultMessage is the message template used when MessageID has no
+	// translation in the language of the context.
+	DefaultMessage string
+	// Funcs are made available to the message template.
+	Funcs template.FuncMap
+}
+
+// LocalizeWith translates a message as described by cfg, in the language
+// negotiated by the middleware. It is an escape hatch for advanced cases
+// that Translate doesn't cover, like custom template functions.
+//
+// The message can render other messages with the "t" template data:
+// "Welcome to {{call .t "app.name"}}", unless the template data has a "t"
+// of its own. go-i18n parses the messages when they are loaded, without
+// custom functions, so t can't be a function of the templates. The nested
+// messages render through Translate and the view helper too.
+func (t *Translator) LocalizeWith(c buffalo.Context, cfg *LocalizeConfig) (string, error) {
+	lang := t.contextLanguage(c)
+	if lang == nil {
+		lang = t.defaultLanguage()
+	}
+	return t.localize(lang, cfg)
+}
+
+// maxNesting is how deep messages can refer to one another with the "t"
+// template function, so circular references fail rather than recurse
+// forever.
+const maxNesting = 10
+
+// localize translates a message as described by cfg, in lang.
+func (t *Translator) localize(lang *language.Language, cfg *LocalizeConfig) (string, error) {
+	return t.localizeNested(lang, cfg, 0)
+}
+
+// localizeNested translates a message referred to by depth messages.
+func (t *Translator) localizeNested(lang *language.Language, cfg *LocalizeConfig, depth int) (string, error) {
+	if depth > maxNesting {
+		return cfg.MessageID, fmt.Errorf("i18n: message %q is nested too deeply, is there a circular reference?", cfg.MessageID)
+	}
+	t.markUsed(t.canonicalID(cfg.MessageID))
+	data := t.templateData(cfg.TemplateData)
+	if _, ok := data["t"]; !ok {
+		data["t"] = t.nestedFunc(lang, cfg.Funcs, depth)
+	}
+	count := cfg.PluralCount
+	if count != nil {
+		data["Count"] = count
+	} else {
+		count = data["Count"]
+	}
+
+	forced := language.Invalid
+	if cfg.PluralCategory != "" {
+		p, err := language.NewPlural(cfg.PluralCategory)
+		if err != nil {
+			return cfg.MessageID, fmt.Errorf("i18n: invalid plural category %q", cfg.PluralCategory)
+		}
+		forced = p
+	}
+
+	src := cfg.DefaultMessage
+	if lang != nil {
+		if tr := t.lookup(lang, cfg.MessageID); tr != nil {
+			pc := language.Plural(language.Other)
+			if forced != language.Invalid {
+				pc = forced
+			} else if n, ok := pluralCount(count); ok {
+				if p, err := lang.Plural(n); err == nil {
+					pc = p
+				}
+			}
+			if tmpl := tr.Template(pc); tmpl != nil && tmpl.String() != "" {
+				src = tmpl.String()
+			}
+		}
+	}
+	if src == "" {
+		return cfg.MessageID, fmt.Errorf("i18n: no translation found for %q", cfg.MessageID)
+	}
+	if !strings.Contains(src, "{{") {
+		return src, nil
+	}
+	if t.LocalizeNumbers && lang != nil {
+		localizeNumbers(lang, data)
+	}
+	if t.BidiIsolates && lang != nil && isRTL(lang) {
+		isolateStrings(data)
+	}
+
+	tmpl, err := template.New(cfg.MessageID).Funcs(cfg.Funcs).Parse(src)
+	if err != nil {
+		return cfg.MessageID, err
+	}
+	bb := &bytes.Buffer{}
+	if err := tmpl.Execute(bb, data); err != nil {
+		return cfg.MessageID, err
+	}
+	return bb.String(), nil
+}
+
+// nestedFunc returns the "t" template data of a message referred to by depth
+// messages: it renders another message, in the same language.
+func (t *Translator) nestedFunc(lang *language.Language, funcs template.FuncMap, depth int) func(string, ...interface{}) (string, error) {
+	return func(id string, nestedData ...interface{}) (string, error) {
+		nested := &LocalizeConfig{MessageID: id, Funcs: funcs}
+		if len(nestedData) > 0 {
+			nested.TemplateData = nestedData[0]
+		}
+		return t.localizeNested(lang, nested, depth+1)
+	}
+}
+
+// withNested adds the "t" template data to the translation arguments of
+// the message identified by id when its translation in lang renders other
+// messages, see LocalizeWith, so that it works with Translate too. The
+// other messages don't pay for the template data map.
+func (t *Translator) withNested(lang *language.Language, id string, args []interface{}) []interface{} {
+	if lang == nil {
+		return args
+	}
+	if tr := t.lookup(lang, id); tr == nil || !isNesting(tr) {
+		return args
+	}
+	count, data := splitArgs(args)
+	merged := toMap(data)
+	if _, ok := merged["t"]; ok {
+		return args
+	}
+	nestedData := make(map[string]interface{}, len(merged)+1)
+	for k, v := range merged {
+		nestedData[k] = v
+	}
+	nestedData["t"] = t.nestedFunc(lang, nil, 0)
+	if count != nil {
+		return []interface{}{count, nestedData}
+	}
+	return []interface{}{nestedData}
+}
+
+// isNesting tells whether a form of tr renders other messages with the "t"
+// template data.
+func isNesting(tr translation.Translation) bool {
+	for _, pc := range pluralCategories {
+		if tmpl := tr.Template(pc); tmpl != nil && strings.Contains(tmpl.String(), ".t ") {
+			return true
+		}
+	}
+	return false
+}
+
+// DryRun renders the message identified by id in lang with sampleData, and
+// reports the placeholders it can't resolve: "Hello {{.Name}}" without a
+// Name would render "Hello <no value>" with Translate. Used in tests, it
+// catches the mismatches between the messages and the data of the calls.
+// Every form of a plural message is rendered, with the "Count" of
+// sampleData, or 1.
+func (t *Translator) DryRun(lang, id string, sampleData map[string]interface{}) error {
+	langs := language.Parse(lang)
+	if len(langs) == 0 {
+		return fmt.Errorf("i18n: invalid language %q", lang)
+	}
+	tr := t.lookup(langs[0], id)
+	if tr == nil {
+		return fmt.Errorf("i18n: no translation found for %q in %s", id, lang)
+	}
+
+	data := t.templateData(sampleData)
+	forms := []language.Plural{language.Other}
+	if isPlural(tr) {
+		forms = pluralCategories
+		if _, ok := data["Count"]; !ok {
+			data["Count"] = 1
+		}
+	}
+	for _, pc := range forms {
+		tmpl := tr.Template(pc)
+		if tmpl == nil || !strings.Contains(tmpl.String(), "{{") {
+			continue
+		}
+		parsed, err := template.New(id).Option("missingkey=error").Parse(tmpl.String())
+		if err != nil {
+			return fmt.Errorf("i18n: message %q in %s: %v", id, lang, err)
+		}
+		bb := &bytes.Buffer{}
+		if err := parsed.Execute(bb, data); err != nil {
+			return fmt.Errorf("i18n: message %q in %s (%s form): %v", id, lang, pc, err)
+		}
+		if strings.Contains(bb.String(), "<no value>") {
+			return fmt.Errorf("i18n: message %q in %s (%s form) has unresolved placeholders: %q", id, lang, pc, bb.String())
+		}
+	}
+	return nil
+}
+
+// defaultLanguage returns the parsed DefaultLanguage, or nil if it isn't a
+// supported language.
+func (t *Translator) defaultLanguage() *language.Language {
+	if langs := language.Parse(t.DefaultLanguage); len(langs) > 0 {
+		return langs[0]
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package i18n
+
+import (
+	"github.com/gobuffalo/buffalo"
+)
+
+// silentOption is the LanguageExtractorOptions key set by the Translator in
+// SilentMode, so that the extractors don't log either.
+const silentOption = "i18n.silent"
+
+// nopLogger is a buffalo.Logger discarding everything, see SilentMode.
+type nopLogger struct{}
+
+func (nopLogger) WithField(string, interface{}) buffalo.Logger     { return nopLogger{} }
+func (nopLogger) WithFields(map[string]interface{}) buffalo.Logger { return nopLogger{} }
+func (nopLogger) Debugf(string, ...interface{})                    {}
+func (nopLogger) Infof(string, ...interface{})                     {}
+func (nopLogger) Printf(string, ...interface{})                    {}
+func (nopLogger) Warnf(string, ...interface{})                     {}
+func (nopLogger) Errorf(string, ...interface{})                    {}
+func (nopLogger) Fatalf(string, ...interface{})                    {}
+func (nopLogger) Debug(...interface{})                             {}
+func (nopLogger) Info(...interface{})                              {}
+func (nopLogger) Warn(...interface{})                              {}
+func (nopLogger) Error(...interface{})                             {}
+func (nopLogger) Fatal(...interface{})                             {}
+func (nopLogger) Panic(...interface{})                             {}
+
+// logger returns the logger to use outside of requests: t.Logger, or a
+// logger discarding everything in SilentMode or without Logger.
+func (t *Translator) logger() buffalo.Logger {
+	if t.SilentMode || t.Logger == nil {
+		return nopLogger{}
+	}
+	return t.Logger
+}
+
+// contextLogger returns the logger of c, or a logger discarding everything
+// in SilentMode or if c has none.
+func (t *Translator) contextLogger(c buffalo.Context) buffalo.Logger {
+	if t.SilentMode {
+		return nopLogger{}
+	}
+	return requestLogger(c)
+}
+
+// requestLogger returns the logger of c, or a logger discarding everything
+// if c has none, as custom contexts may not.
+func requestLogger(c buffalo.Context) buffalo.Logger {
+	if l := c.Logger(); l != nil {
+		return l
+	}
+	return nopLogger{}
+}
@@ -0,0 +1,21 @@
+package i18n
+
+import (
+	"html/template"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/github_flavored_markdown"
+)
+
+// TranslateMarkdown is like Translate, for the messages written in
+// markdown: the translation is rendered to HTML, sanitized as the plush
+// "markdown" helper does, and returned as template.HTML so the templates
+// don't escape it. It returns an error, along with the escaped message ID,
+// if the message has no translation.
+func (t *Translator) TranslateMarkdown(c buffalo.Context, translationID string, args ...interface{}) (template.HTML, error) {
+	s, err := t.translateChecked(c, translationID, args...)
+	if err != nil {
+		return template.HTML(template.HTMLEscapeString(s)), err
+	}
+	return template.HTML(github_flavored_markdown.Markdown([]byte(s))), nil
+}
@@ -0,0 +1,550 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/nicksnyder/go-i18n/i18n"
+	"github.com/nicksnyder/go-i18n/i18n/bundle"
+	"github.com/nicksnyder/go-i18n/i18n/language"
+	"github.com/nicksnyder/go-i18n/i18n/translation"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+	"gopkg.in/yaml.v2"
+)
+
+// pluralCategories lists all the CLDR plural categories, in their usual order.
+var pluralCategories = []language.Plural{
+	language.Zero,
+	language.One,
+	language.Two,
+	language.Few,
+	language.Many,
+	language.Other,
+}
+
+// ExportedMessage is a loaded translation, as returned by ExportMessages.
+type ExportedMessage struct {
+	ID string
+	// Description gives translators some context about the message.
+	// It is read from the optional "description" field of a message
+	// in the locale files.
+	Description string
+	// Translation is the value of a non-plural message.
+	Translation string
+	// Plural holds the values of a plural message, by CLDR plural
+	// category ("one", "other", ...).
+	Plural map[string]string
+}
+
+// message is a translation loaded by a Translator, with its metadata.
+type message struct {
+	translation translation.Translation
+	description string
+	// source is the path of the locale file defining the message, if any
+	source string
+}
+
+// messageIndex keeps track of the messages loaded by a Translator,
+// by language tag and message ID.
+type messageIndex map[string]map[string]*message
+
+// add indexes translations for the language tag. descriptions and sources
+// hold the descriptions and the source files of the messages, by ID, and
+// can be nil.
+func (mi messageIndex) add(tag string, translations []translation.Translation, descriptions, sources map[string]string) {
+	if mi[tag] == nil {
+		mi[tag] = map[string]*message{}
+	}
+	for _, tr := range translations {
+		if m, ok := mi[tag][tr.ID()]; ok {
+			m.translation = m.translation.Merge(tr)
+			if d := descriptions[tr.ID()]; d != "" {
+				m.description = d
+			}
+			if src := sources[tr.ID()]; src != "" {
+				m.source = src
+			}
+			continue
+		}
+		mi[tag][tr.ID()] = &message{
+			translation: tr,
+			description: descriptions[tr.ID()],
+			source:      sources[tr.ID()],
+		}
+	}
+}
+
+// addTo adds the messages of the index to messages, and returns it.
+func (mi messageIndex) addTo(messages messageIndex) messageIndex {
+	for tag, msgs := range mi {
+		translations := make([]translation.Translation, 0, len(msgs))
+		descriptions := make(map[string]string, len(msgs))
+		sources := make(map[string]string, len(msgs))
+		for id, m := range msgs {
+			// the index merges messages in place, keep mi intact
+			translations = append(translations, cloneTranslation(m.translation))
+			descriptions[id] = m.description
+			sources[id] = m.source
+		}
+		messages.add(tag, translations, descriptions, sources)
+	}
+	return messages
+}
+
+// empty tells whether the index holds no message, in any language.
+func (mi messageIndex) empty() bool {
+	for _, msgs := range mi {
+		if len(msgs) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bundle returns a go-i18n bundle holding the messages of the index.
+func (mi messageIndex) bundle() *bundle.Bundle {
+	b := bundle.New()
+	mi.addTranslations(b.AddTranslation)
+	return b
+}
+
+// addTranslations adds the messages of the index to a go-i18n bundle with
+// add, by language.
+func (mi messageIndex) addTranslations(add func(*language.Language, ...translation.Translation)) {
+	for tag, msgs := range mi {
+		langs := language.Parse(tag)
+		if len(langs) != 1 {
+			continue
+		}
+		translations := make([]translation.Translation, 0, len(msgs))
+		for _, m := range msgs {
+			// the index merges messages in place, keep the bundle apart
+			translations = append(translations, cloneTranslation(m.translation))
+		}
+		add(langs[0], translations...)
+	}
+}
+
+// ExportMessages returns the messages loaded for the given language,
+// sorted by ID. Each message comes with its description, so it can be
+// handed over to translators with some context.
+func (t *Translator) ExportMessages(lang string) []ExportedMessage {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	msgs := t.messages[language.NormalizeTag(lang)]
+	exported := make([]ExportedMessage, 0, len(msgs))
+	for _, m := range msgs {
+		exported = append(exported, exportMessage(m.translation, m.description))
+	}
+	sort.Slice(exported, func(i, j int) bool {
+		return exported[i].ID < exported[j].ID
+	})
+	return exported
+}
+
+// exportMessage converts a translation to an ExportedMessage.
+func exportMessage(tr translation.Translation, description string) ExportedMessage {
+	em := ExportedMessage{
+		ID:          tr.ID(),
+		Description: description,
+	}
+	if isPlural(tr) {
+		em.Plural = map[string]string{}
+		for _, pc := range pluralCategories {
+			if tmpl := tr.Template(pc); tmpl != nil && tmpl.String() != "" {
+				em.Plural[string(pc)] = tmpl.String()
+			}
+		}
+	} else if tmpl := tr.Template(language.Other); tmpl != nil {
+		em.Translation = tmpl.String()
+	}
+	return em
+}
+
+// MessageFile is a locale file, as parsed by ParseBundleFiles.
+type MessageFile struct {
+	// Path of the file in its fs.FS.
+	Path string
+	// Tag is the language of the file.
+	Tag string
+	// Messages of the file, sorted by ID.
+	Messages []ExportedMessage
+}
+
+// ParseBundleFiles parses the locale files of fsys as Load does, without
+// loading them, for tools such as key manifests or coverage reports: the
+// fragments are parsed with the files including them, and the messages of
+// the files in the directories of domains have the IDs of their domain (see
+// Translator.Domains). As for Load, a broken file doesn't stop the walk:
+// the other files are returned, along with LoadErrors.
+func ParseBundleFiles(fsys fs.FS, domains ...string) ([]*MessageFile, error) {
+	t := newTranslator(fsys, "")
+	t.Domains = domains
+	var errs LoadErrors
+	files := []*MessageFile{}
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		if d.IsDir() || isFragment(path) {
+			return nil
+		}
+
+		lf := t.loadFile(path, &errs)
+		if lf == nil {
+			return nil
+		}
+		mf := &MessageFile{
+			Path:     path,
+			Tag:      lf.lang.Tag,
+			Messages: make([]ExportedMessage, 0, len(lf.translations)),
+		}
+		for _, tr := range lf.translations {
+			mf.Messages = append(mf.Messages, exportMessage(tr, lf.descriptions[tr.ID()]))
+		}
+		files = append(files, mf)
+		return nil
+	})
+	if err != nil {
+		return files, err
+	}
+	if len(errs) > 0 {
+		return files, errs
+	}
+	return files, nil
+}
+
+// isPlural tells whether tr has one template per plural category.
+func isPlural(tr translation.Translation) bool {
+	// Non-plural translations are flattened as a single "other" entry.
+	_, single := tr.MarshalFlatInterface().(map[string]interface{})
+	return !single
+}
+
+// readLocaleFile reads the locale file at path as UTF-8. Some editors save
+// files with a byte order mark, or in UTF-16: the BOM is dropped, and UTF-16
+// files (detected by their BOM) are converted.
+func readLocaleFile(fsys fs.FS, path string) ([]byte, error) {
+	b, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeLocaleFile(b)
+}
+
+// decodeLocaleFile converts the content of a locale file to UTF-8, see
+// readLocaleFile.
+func decodeLocaleFile(b []byte) ([]byte, error) {
+	b, _, err := transform.Bytes(unicode.BOMOverride(unicode.UTF8.NewDecoder()), b)
+	return b, err
+}
+
+// parseTranslationFile parses a locale file on its own, so its translations
+// can be inspected before they are added to the i18n bundle.
+func parseTranslationFile(filename string, buf []byte) (*language.Language, []translation.Translation, error) {
+	b := bundle.New()
+	if err := b.ParseTranslationFileBytes(filename, buf); err != nil {
+		return nil, nil, err
+	}
+	// the language was already validated by ParseTranslationFileBytes
+	lang := language.Parse(filepath.Base(filename))[0]
+
+	translations := make([]translation.Translation, 0)
+	for _, tr := range b.Translations()[lang.Tag] {
+		translations = append(translations, tr)
+	}
+	sort.Sort(translation.SortableByID(translations))
+	return lang, translations, nil
+}
+
+// parseDescriptions collects the optional "description" field of the
+// messages in a locale file. go-i18n itself ignores this field. Only the
+// standard (list) format can hold descriptions.
+func parseDescriptions(filename string, buf []byte) map[string]string {
+	var data []map[string]interface{}
+	var err error
+	switch filepath.Ext(filename) {
+	case ".json":
+		err = json.Unmarshal(buf, &data)
+	case ".yaml":
+		err = yaml.Unmarshal(buf, &data)
+	default:
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+
+	descriptions := map[string]string{}
+	for _, d := range data {
+		id, _ := d["id"].(string)
+		if desc, ok := d["description"].(string); ok && id != "" {
+			descriptions[id] = desc
+		}
+	}
+	return descriptions
+}
+
+// lookup returns the message loaded by this Translator for the given language
+// and ID. As in go-i18n, the messages of a more specific language can be used
+// for a less specific one (the "fr-fr" messages for "fr").
+func (t *Translator) lookup(lang *language.Language, id string) translation.Translation {
+	if m := t.lookupMessage(lang, id); m != nil {
+		return m.translation
+	}
+	return nil
+}
+
+// MessageSource returns the path of the locale file the message identified
+// by id comes from in lang, so that an editor can write the changes of the
+// message back to it. The path is relative to FS, or the name given to
+// AddFile; for an included message, it is the path of the included file.
+// It returns false if the message isn't loaded for lang, or wasn't loaded
+// from a file (see AddTranslation).
+func (t *Translator) MessageSource(lang, id string) (string, bool) {
+	langs := language.Parse(lang)
+	if len(langs) == 0 {
+		return "", false
+	}
+	if m := t.lookupMessage(langs[0], id); m != nil && m.source != "" {
+		return m.source, true
+	}
+	return "", false
+}
+
+// lookupMessage returns the message loaded for the given language and ID,
+// with its metadata, see lookup.
+func (t *Translator) lookupMessage(lang *language.Language, id string) *message {
+	id = t.canonicalID(id)
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if m, ok := t.messages[lang.Tag][id]; ok {
+		return m
+	}
+	tags := make([]string, 0, len(t.messages))
+	for tag := range t.messages {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, lang.Tag+"-") {
+			if m, ok := t.messages[tag][id]; ok {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// canonicalID returns the form of id used to index the messages: id itself,
+// or its lowercase form with the CaseInsensitiveIDs option.
+func (t *Translator) canonicalID(id string) string {
+	if t.CaseInsensitiveIDs {
+		return strings.ToLower(id)
+	}
+	return id
+}
+
+// loadedID returns the ID a message of the locale files is loaded with: its
+// ID in the Namespace, in canonical form.
+func (t *Translator) loadedID(id string) string {
+	if t.Namespace != "" {
+		id = t.Namespace + "." + id
+	}
+	return t.canonicalID(id)
+}
+
+// loadedTranslations returns translations with the IDs and the templates
+// they are loaded with.
+func (t *Translator) loadedTranslations(translations []translation.Translation) []translation.Translation {
+	if !t.CaseInsensitiveIDs && t.Namespace == "" && !t.TrimWhitespace {
+		return translations
+	}
+	text := func(s string) string { return s }
+	if t.TrimWhitespace {
+		text = trimWhitespace
+	}
+	loaded := make([]translation.Translation, 0, len(translations))
+	for _, tr := range translations {
+		if id := t.loadedID(tr.ID()); id != tr.ID() || t.TrimWhitespace {
+			tr = rewriteTranslation(tr, id, text)
+		}
+		loaded = append(loaded, tr)
+	}
+	return loaded
+}
+
+// trimWhitespace normalizes the line endings of s to "\n", and removes the
+// trailing whitespace of its lines.
+func trimWhitespace(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// loadedDescriptions returns descriptions by the IDs they are loaded with.
+func (t *Translator) loadedDescriptions(descriptions map[string]string) map[string]string {
+	if (!t.CaseInsensitiveIDs && t.Namespace == "") || descriptions == nil {
+		return descriptions
+	}
+	loaded := make(map[string]string, len(descriptions))
+	for id, d := range descriptions {
+		loaded[t.loadedID(id)] = d
+	}
+	return loaded
+}
+
+// rewriteTranslation returns a copy of tr with the given ID, and its
+// templates rewritten by text.
+func rewriteTranslation(tr translation.Translation, id string, text func(string) string) translation.Translation {
+	data := map[string]interface{}{"id": id}
+	if isPlural(tr) {
+		plurals := map[string]interface{}{}
+		for _, pc := range pluralCategories {
+			if tmpl := tr.Template(pc); tmpl != nil {
+				plurals[string(pc)] = text(tmpl.String())
+			}
+		}
+		data["translation"] = plurals
+	} else {
+		data["translation"] = ""
+		if tmpl := tr.Template(language.Other); tmpl != nil {
+			data["translation"] = text(tmpl.String())
+		}
+	}
+	// the templates of tr were parsed already, and text only changes
+	// whitespace, so this can't fail
+	rewritten, _ := translation.NewTranslation(data)
+	return rewritten
+}
+
+// languageKey is the context key of the language of the "T" translation
+// function, set along with it by the middleware.
+const languageKey = "i18n.language"
+
+// contextLanguage returns the language used by the "T" translation function
+// of the context, if any.
+func contextLanguage(c buffalo.Context) *language.Language {
+	lang, _ := c.Value(languageKey).(*language.Language)
+	return lang
+}
+
+// toMap converts template data, given as a map or a struct, to a map.
+func toMap(data interface{}) map[string]interface{} {
+	if m, ok := data.(map[string]interface{}); ok {
+		return m
+	}
+	m := map[string]interface{}{}
+	v := reflect.ValueOf(data)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return m
+	}
+	for i := 0; i < v.NumField(); i++ {
+		// skip unexported fields
+		if f := v.Type().Field(i); f.PkgPath == "" {
+			m[f.Name] = v.Field(i).Interface()
+		}
+	}
+	return m
+}
+
+// Merge adds the messages loaded by other to this Translator, so that
+// separately loaded bundles (e.g. from plugins) can be served by a single
+// middleware. On conflicts, other's messages win, unless t.ConflictPolicy
+// is FirstWins or ErrorOnConflict; the conflicts are returned as
+// LoadErrors with the latter.
+func (t *Translator) Merge(other *Translator) error {
+	if other == t {
+		return nil
+	}
+
+	other.mu.RLock()
+	incoming := messageIndex{}
+	for tag, msgs := range other.messages {
+		incoming[tag] = map[string]*message{}
+		for id, m := range msgs {
+			incoming[tag][id] = &message{
+				translation: cloneTranslation(m.translation),
+				description: m.description,
+				source:      m.source,
+			}
+		}
+	}
+	other.mu.RUnlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.messages == nil {
+		t.messages = messageIndex{}
+	}
+	if t.extra == nil {
+		t.extra = messageIndex{}
+	}
+	var errs LoadErrors
+	for tag, msgs := range incoming {
+		langs := language.Parse(tag)
+		if len(langs) != 1 {
+			return fmt.Errorf("invalid language %q", tag)
+		}
+
+		translations := []translation.Translation{}
+		descriptions := map[string]string{}
+		sources := map[string]string{}
+		for id, m := range msgs {
+			if _, ok := t.messages[tag][id]; ok && t.ConflictPolicy != LastWins {
+				if t.ConflictPolicy == ErrorOnConflict {
+					errs = append(errs, fmt.Errorf("i18n: message %q is already defined for %s", id, tag))
+				}
+				continue
+			}
+			translations = append(translations, m.translation)
+			descriptions[id] = m.description
+			sources[id] = m.source
+		}
+		if t.bundle != nil {
+			t.bundle.AddTranslation(langs[0], cloneTranslations(translations)...)
+		}
+		i18n.AddTranslation(langs[0], cloneTranslations(translations)...)
+		t.messages.add(tag, translations, descriptions, sources)
+		// kept by the later loads of t
+		t.extra.add(tag, cloneTranslations(translations), descriptions, sources)
+	}
+	t.available = nil
+	t.generation++
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// cloneTranslation returns a copy of tr. go-i18n merges the translations
+// added for an existing message in place, so the translations indexed by a
+// Translator are never shared with the go-i18n bundle.
+func cloneTranslation(tr translation.Translation) translation.Translation {
+	return tr.UntranslatedCopy().Merge(tr)
+}
+
+func cloneTranslations(translations []translation.Translation) []translation.Translation {
+	clones := make([]translation.Translation, 0, len(translations))
+	for _, tr := range translations {
+		clones = append(clones, cloneTranslation(tr))
+	}
+	return clones
+}
@@ -0,0 +1,58 @@
+package i18n
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/nicksnyder/go-i18n/i18n"
+	"github.com/nicksnyder/go-i18n/i18n/language"
+	xlanguage "golang.org/x/text/language"
+	xmessage "golang.org/x/text/message"
+)
+
+// localizedNumber is a number of the template data, printed in the format
+// of a language ("1,234.5" in English, "1 234,5" in French).
+type localizedNumber struct {
+	printer *xmessage.Printer
+	value   interface{}
+}
+
+func (n localizedNumber) String() string {
+	return n.printer.Sprint(n.value)
+}
+
+// localizeNumbers replaces the numbers of data with localizedNumbers, for
+// the LocalizeNumbers option.
+func localizeNumbers(lang *language.Language, data map[string]interface{}) {
+	tag, err := xlanguage.Parse(lang.Tag)
+	if err != nil {
+		return
+	}
+	printer := xmessage.NewPrinter(tag)
+	for k, v := range data {
+		switch v.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			data[k] = localizedNumber{printer: printer, value: v}
+		}
+	}
+}
+
+// localizedTfunc returns a translation function rendering the messages in
+// the language of c with localized numbers, or isolated strings, see
+// LocalizeNumbers and BidiIsolates. go-i18n renders the template data as is,
+// so the messages are rendered by localize instead. T is returned as is when
+// c has no language.
+func (t *Translator) localizedTfunc(c buffalo.Context, T i18n.TranslateFunc) i18n.TranslateFunc {
+	lang := t.contextLanguage(c)
+	if lang == nil {
+		return T
+	}
+	return func(translationID string, args ...interface{}) string {
+		count, data := splitArgs(args)
+		// localize returns the ID for a missing message, as go-i18n does
+		s, _ := t.localize(lang, &LocalizeConfig{
+			MessageID:    translationID,
+			TemplateData: data,
+			PluralCount:  count,
+		})
+		return s
+	}
+}
@@ -0,0 +1,61 @@
+package i18n
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/nicksnyder/go-i18n/i18n/bundle"
+	"github.com/nicksnyder/go-i18n/i18n/language"
+	"github.com/nicksnyder/go-i18n/i18n/translation"
+)
+
+// WithOverlay adds translations to the overlay of tenant: with a
+// TenantSelector, Translate looks the messages up in the overlay of the
+// tenant of the request first, then in the messages of the Translator. A
+// tenant can customize some messages this way, and inherit the others,
+// with a single Translator for all the tenants.
+//
+// The overlay messages are looked up in the language of the response, so
+// they must use the language tags of the locale files ("fr-fr" rather than
+// "fr" if the locale file is "all.fr-fr.yaml").
+func (t *Translator) WithOverlay(tenant string, lang *language.Language, translations ...translation.Translation) {
+	translations = t.loadedTranslations(translations)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.overlays == nil {
+		t.overlays = map[string]*bundle.Bundle{}
+	}
+	overlay, ok := t.overlays[tenant]
+	if !ok {
+		overlay = bundle.New()
+		t.overlays[tenant] = overlay
+	}
+	overlay.AddTranslation(lang, cloneTranslations(translations)...)
+}
+
+// overlayTranslate translates translationID with the overlay of the tenant
+// of the request of c, if it defines the message in the language of c.
+func (t *Translator) overlayTranslate(c buffalo.Context, translationID string, args ...interface{}) (string, bool) {
+	if t.TenantSelector == nil {
+		return "", false
+	}
+	tenant := t.TenantSelector(c)
+	t.mu.RLock()
+	overlay, ok := t.overlays[tenant]
+	t.mu.RUnlock()
+	if !ok {
+		return "", false
+	}
+	lang := t.contextLanguage(c)
+	if lang == nil {
+		return "", false
+	}
+	T, err := overlay.Tfunc(lang.Tag)
+	if err != nil {
+		// no overlay message in this language
+		return "", false
+	}
+	if s := T(translationID, args...); s != translationID {
+		return s, true
+	}
+	return "", false
+}
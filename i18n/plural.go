@@ -0,0 +1,248 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/nicksnyder/go-i18n/i18n/language"
+	"golang.org/x/text/feature/plural"
+	xlanguage "golang.org/x/text/language"
+)
+
+// TranslateOrdinal returns the translation of the plural message identified by
+// translationID, selecting its form with the CLDR ordinal rules ("1st", "2nd",
+// "3rd", "4th") rather than the cardinal ones ("1 item", "2 items").
+// count is available in the message template as .Count, along with the
+// fields of data.
+//
+// If there is no translation for translationID, then the translationID itself is returned.
+func (t *Translator) TranslateOrdinal(c buffalo.Context, translationID string, count int, data interface{}) string {
+	t.markUsed(t.canonicalID(translationID))
+	lang := t.contextLanguage(c)
+	if lang == nil {
+		return translationID
+	}
+	tr := t.lookup(lang, translationID)
+	if tr == nil {
+		return translationID
+	}
+
+	tmpl := tr.Template(ordinalCategory(lang.Tag, count))
+	if tmpl == nil || tmpl.String() == "" {
+		tmpl = tr.Template(language.Other)
+	}
+	if tmpl == nil {
+		return translationID
+	}
+
+	d := t.templateData(data)
+	d["Count"] = count
+	return tmpl.Execute(d)
+}
+
+// TranslatePluralCategory returns the translation of the plural message
+// identified by translationID in the given plural category ("zero", "one",
+// "two", "few", "many" or "other"), whatever the count. It is an escape
+// hatch for the copy that doesn't follow the CLDR rules of its language.
+// The fields of data are available in the message template, including its
+// Count, if any.
+//
+// If there is no translation for translationID in category, then the
+// translationID itself is returned.
+func (t *Translator) TranslatePluralCategory(c buffalo.Context, translationID, category string, data interface{}) string {
+	s, _ := t.LocalizeWith(c, &LocalizeConfig{
+		MessageID:      translationID,
+		TemplateData:   data,
+		PluralCategory: category,
+	})
+	return s
+}
+
+// pluralSamples are the counts tried by PluralPreview, in order, to find a
+// count of each plural category: the small integers, the large numbers of
+// "many" in French or Spanish, then the decimals of "other" in Russian.
+var pluralSamples = func() []interface{} {
+	samples := make([]interface{}, 0, 106)
+	for i := 0; i <= 100; i++ {
+		samples = append(samples, i)
+	}
+	return append(samples, 1000000, "0.5", "1.5", "2.5", "5.5")
+}()
+
+// PluralPreview renders the message identified by id in lang for a count of
+// each plural category of the language, so that the translators of a plural
+// message can check all its forms at once. The renderings are returned by
+// category ("one", "few"...); the count is available as .Count in the
+// messages. A message that isn't plural only has its "other" rendering.
+func (t *Translator) PluralPreview(lang, id string) (map[string]string, error) {
+	langs := language.Parse(lang)
+	if len(langs) == 0 {
+		return nil, fmt.Errorf("i18n: invalid language %q", lang)
+	}
+	tr := t.lookup(langs[0], id)
+	if tr == nil {
+		return nil, fmt.Errorf("i18n: no translation found for %q in %s", id, lang)
+	}
+
+	wanted := map[language.Plural]bool{language.Other: true}
+	if isPlural(tr) {
+		for pc := range langs[0].Plurals {
+			wanted[pc] = true
+		}
+	}
+	preview := map[string]string{}
+	for _, count := range pluralSamples {
+		pc, err := langs[0].Plural(count)
+		if err != nil || !wanted[pc] {
+			continue
+		}
+		delete(wanted, pc)
+		s, err := t.localize(langs[0], &LocalizeConfig{MessageID: id, PluralCount: count})
+		if err != nil {
+			return nil, fmt.Errorf("i18n: message %q in %s (%s form): %v", id, lang, pc, err)
+		}
+		preview[string(pc)] = s
+	}
+	return preview, nil
+}
+
+// ValidatePlurals checks that the plural messages define every CLDR plural
+// category of their language, e.g. "one", "few", "many" and "other" in
+// Russian. It returns an error per missing form, so the gaps can be caught
+// at startup or in a test rather than for some counts only.
+func (t *Translator) ValidatePlurals() []error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	tags := make([]string, 0, len(t.messages))
+	for tag := range t.messages {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	var errs []error
+	for _, tag := range tags {
+		langs := language.Parse(tag)
+		if len(langs) != 1 {
+			continue
+		}
+		ids := make([]string, 0, len(t.messages[tag]))
+		for id, m := range t.messages[tag] {
+			if isPlural(m.translation) {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			tr := t.messages[tag][id].translation
+			for _, pc := range pluralCategories {
+				if _, ok := langs[0].Plurals[pc]; !ok {
+					continue
+				}
+				if tmpl := tr.Template(pc); tmpl == nil || tmpl.String() == "" {
+					errs = append(errs, fmt.Errorf("i18n: message %q in %s is missing the plural form %q", id, tag, pc))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// PluralCategory returns the CLDR plural category ("zero", "one", "two",
+// "few", "many" or "other") of count in lang, i.e. the form of the plural
+// messages used for count: 3 is "few" in Polish, 5 is "many". As for
+// the plural messages, count is an integer, a float, or a number formatted
+// as a string, whose trailing zeros matter: "1.0" is "other" in English.
+// "other" is returned for an unknown language or an invalid count.
+func PluralCategory(lang string, count interface{}) string {
+	tag, err := xlanguage.Parse(lang)
+	if err != nil {
+		return string(language.Other)
+	}
+	i, v, w, f, t, ok := pluralOperands(count)
+	if !ok {
+		return string(language.Other)
+	}
+	return string(pluralForm(plural.Cardinal.MatchPlural(tag, i, v, w, f, t)))
+}
+
+// pluralOperands returns the CLDR plural operands of count: its integer
+// digits (i), the number of its visible fraction digits with (v) and
+// without (w) trailing zeros, and these fraction digits with (f) and
+// without (t) trailing zeros.
+func pluralOperands(count interface{}) (i, v, w, f, t int, ok bool) {
+	var s string
+	switch n := count.(type) {
+	case int:
+		s = strconv.FormatInt(int64(n), 10)
+	case int8:
+		s = strconv.FormatInt(int64(n), 10)
+	case int16:
+		s = strconv.FormatInt(int64(n), 10)
+	case int32:
+		s = strconv.FormatInt(int64(n), 10)
+	case int64:
+		s = strconv.FormatInt(n, 10)
+	case float32:
+		s = strconv.FormatFloat(float64(n), 'f', -1, 32)
+	case float64:
+		s = strconv.FormatFloat(n, 'f', -1, 64)
+	case string:
+		s = strings.TrimSpace(n)
+	default:
+		return 0, 0, 0, 0, 0, false
+	}
+
+	s = strings.TrimPrefix(s, "-")
+	intPart, frac := s, ""
+	if dot := strings.IndexByte(s, '.'); dot >= 0 {
+		intPart, frac = s[:dot], s[dot+1:]
+	}
+	trimmed := strings.TrimRight(frac, "0")
+	var err error
+	if i, err = strconv.Atoi(intPart); err != nil {
+		return 0, 0, 0, 0, 0, false
+	}
+	if frac != "" {
+		if f, err = strconv.Atoi(frac); err != nil || f < 0 {
+			return 0, 0, 0, 0, 0, false
+		}
+	}
+	if trimmed != "" {
+		t, _ = strconv.Atoi(trimmed)
+	}
+	return i, len(frac), len(trimmed), f, t, i >= 0
+}
+
+// ordinalCategory returns the CLDR ordinal plural category of n in lang.
+func ordinalCategory(lang string, n int) language.Plural {
+	if n < 0 {
+		n = -n
+	}
+	tag, err := xlanguage.Parse(lang)
+	if err != nil {
+		return language.Other
+	}
+	return pluralForm(plural.Ordinal.MatchPlural(tag, n, 0, 0, 0, 0))
+}
+
+// pluralForm converts a golang.org/x/text plural form to a go-i18n plural
+// category.
+func pluralForm(f plural.Form) language.Plural {
+	switch f {
+	case plural.Zero:
+		return language.Zero
+	case plural.One:
+		return language.One
+	case plural.Two:
+		return language.Two
+	case plural.Few:
+		return language.Few
+	case plural.Many:
+		return language.Many
+	}
+	return language.Other
+}
@@ -0,0 +1,141 @@
+package i18n
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	xlanguage "golang.org/x/text/language"
+)
+
+// relativeUnit is a unit of the relative durations, with its length.
+type relativeUnit struct {
+	name   string
+	length time.Duration
+	// limit is the number of units from which the next unit is used
+	limit float64
+}
+
+// relativeUnits are the units of FormatRelative, from the smallest one.
+var relativeUnits = []relativeUnit{
+	{"second", time.Second, 45},
+	{"minute", time.Minute, 45},
+	{"hour", time.Hour, 22},
+	{"day", 24 * time.Hour, 6},
+	{"week", 7 * 24 * time.Hour, 4},
+	{"month", 30 * 24 * time.Hour, 11},
+	{"year", 365 * 24 * time.Hour, math.Inf(1)},
+}
+
+// relativeWords is the wording of the relative durations in a language.
+type relativeWords struct {
+	now, yesterday, tomorrow string
+	// past and future wrap a duration: "%s ago", "in %s"
+	past, future string
+	// units holds the duration formats by unit, then by plural category
+	// ("one" and "other"; "other" is used for the missing categories). Less
+	// than a minute is "now".
+	units map[string]map[string]string
+}
+
+// relativeTexts holds the wording of the relative durations, by base
+// language. The other languages use the English one.
+var relativeTexts = map[string]relativeWords{
+	"en": {
+		now: "now", yesterday: "yesterday", tomorrow: "tomorrow",
+		past: "%s ago", future: "in %s",
+		units: map[string]map[string]string{
+			"minute": {"one": "%d minute", "other": "%d minutes"},
+			"hour":   {"one": "%d hour", "other": "%d hours"},
+			"day":    {"one": "%d day", "other": "%d days"},
+			"week":   {"one": "%d week", "other": "%d weeks"},
+			"month":  {"one": "%d month", "other": "%d months"},
+			"year":   {"one": "%d year", "other": "%d years"},
+		},
+	},
+	"fr": {
+		now: "maintenant", yesterday: "hier", tomorrow: "demain",
+		past: "il y a %s", future: "dans %s",
+		units: map[string]map[string]string{
+			"minute": {"one": "%d minute", "other": "%d minutes"},
+			"hour":   {"one": "%d heure", "other": "%d heures"},
+			"day":    {"one": "%d jour", "other": "%d jours"},
+			"week":   {"one": "%d semaine", "other": "%d semaines"},
+			"month":  {"one": "%d mois", "other": "%d mois"},
+			"year":   {"one": "%d an", "other": "%d ans"},
+		},
+	},
+	"de": {
+		now: "jetzt", yesterday: "gestern", tomorrow: "morgen",
+		past: "vor %s", future: "in %s",
+		units: map[string]map[string]string{
+			"minute": {"one": "%d Minute", "other": "%d Minuten"},
+			"hour":   {"one": "%d Stunde", "other": "%d Stunden"},
+			"day":    {"one": "%d Tag", "other": "%d Tagen"},
+			"week":   {"one": "%d Woche", "other": "%d Wochen"},
+			"month":  {"one": "%d Monat", "other": "%d Monaten"},
+			"year":   {"one": "%d Jahr", "other": "%d Jahren"},
+		},
+	},
+	"es": {
+		now: "ahora", yesterday: "ayer", tomorrow: "mañana",
+		past: "hace %s", future: "dentro de %s",
+		units: map[string]map[string]string{
+			"minute": {"one": "%d minuto", "other": "%d minutos"},
+			"hour":   {"one": "%d hora", "other": "%d horas"},
+			"day":    {"one": "%d día", "other": "%d días"},
+			"week":   {"one": "%d semana", "other": "%d semanas"},
+			"month":  {"one": "%d mes", "other": "%d meses"},
+			"year":   {"one": "%d año", "other": "%d años"},
+		},
+	},
+}
+
+// FormatRelative returns the time between now and t in words, in lang:
+// "3 days ago", "in 2 hours", "yesterday" or "now" in English. The duration
+// is rounded to its largest unit, up to years. The wording is built in for
+// English, French, German and Spanish; the other languages use the English
+// one.
+func FormatRelative(lang string, t time.Time, now time.Time) string {
+	words := relativeTexts["en"]
+	base := "en"
+	if tag, err := xlanguage.Parse(lang); err == nil {
+		b, _ := tag.Base()
+		if w, ok := relativeTexts[b.String()]; ok {
+			words, base = w, b.String()
+		}
+	}
+
+	// in seconds rather than as a time.Duration, which saturates at about
+	// 292 years: a zero time.Time is some 2000 years ago, not "now"
+	d := float64(t.Unix()-now.Unix()) + float64(t.Nanosecond()-now.Nanosecond())/1e9
+	abs := math.Abs(d)
+	unit := relativeUnits[0]
+	n := abs / unit.length.Seconds()
+	for _, next := range relativeUnits[1:] {
+		if math.Round(n) < unit.limit {
+			break
+		}
+		unit = next
+		n = abs / unit.length.Seconds()
+	}
+	count := int(math.Round(n))
+
+	switch {
+	case unit.name == "second":
+		return words.now
+	case unit.name == "day" && count == 1 && d < 0:
+		return words.yesterday
+	case unit.name == "day" && count == 1:
+		return words.tomorrow
+	}
+	forms := words.units[unit.name]
+	format, ok := forms[PluralCategory(base, count)]
+	if !ok {
+		format = forms["other"]
+	}
+	if d < 0 {
+		return fmt.Sprintf(words.past, fmt.Sprintf(format, count))
+	}
+	return fmt.Sprintf(words.future, fmt.Sprintf(format, count))
+}
@@ -0,0 +1,67 @@
+package i18n
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+)
+
+// TranslateTZ is like Translate, but the dates (time.Time values) of the
+// template data are converted to loc first, so that a message like
+// "Starts at {{.Start.Format "15:04"}}" shows the time of the user rather
+// than the one of the server. With a nil loc, it is the same as Translate.
+func (t *Translator) TranslateTZ(c buffalo.Context, translationID string, loc *time.Location, args ...interface{}) string {
+	if loc == nil {
+		return t.Translate(c, translationID, args...)
+	}
+	zoned := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		zoned = append(zoned, inLocation(arg, loc))
+	}
+	return t.Translate(c, translationID, zoned...)
+}
+
+// inLocation returns arg with its dates converted to loc. Template data
+// holding dates is returned as a map; any other argument is left untouched.
+func inLocation(arg interface{}, loc *time.Location) interface{} {
+	switch v := arg.(type) {
+	case time.Time:
+		return v.In(loc)
+	case *time.Time:
+		if v != nil {
+			return v.In(loc)
+		}
+		return v
+	}
+
+	if _, ok := arg.(map[string]interface{}); !ok {
+		v := reflect.ValueOf(arg)
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return arg
+		}
+	}
+
+	data := toMap(arg)
+	zoned := make(map[string]interface{}, len(data))
+	hasDates := false
+	for k, v := range data {
+		switch d := v.(type) {
+		case time.Time:
+			v, hasDates = d.In(loc), true
+		case *time.Time:
+			if d != nil {
+				v, hasDates = d.In(loc), true
+			}
+		}
+		zoned[k] = v
+	}
+	if !hasDates {
+		// keep the methods of the data available to the template
+		return arg
+	}
+	return zoned
+}
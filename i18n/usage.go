@@ -0,0 +1,44 @@
+package i18n
+
+import "sort"
+
+// markUsed records that the message id was translated, with TrackUsage.
+func (t *Translator) markUsed(id string) {
+	if t.TrackUsage {
+		t.used.Store(id, true)
+	}
+}
+
+// UsedKeys returns the IDs of the messages translated so far, sorted. The
+// messages are only recorded when the TrackUsage option is set. Compared
+// to ListMessageIDs after running the tests, it shows the messages that
+// are likely not used anymore.
+func (t *Translator) UsedKeys() []string {
+	keys := []string{}
+	t.used.Range(func(id, _ interface{}) bool {
+		keys = append(keys, id.(string))
+		return true
+	})
+	sort.Strings(keys)
+	return keys
+}
+
+// ListMessageIDs returns the IDs of the messages loaded for any language,
+// sorted.
+func (t *Translator) ListMessageIDs() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	seen := map[string]bool{}
+	ids := []string{}
+	for _, msgs := range t.messages {
+		for id := range msgs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,77 @@
+package i18n
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// extractorOptionKeys maps the options of the built-in extractors to the
+// extractor using each of them.
+var extractorOptionKeys = map[string]LanguageExtractor{
+	"CookieName":     CookieLanguageExtractor,
+	"SessionName":    SessionLanguageExtractor,
+	"URLPrefixName":  URLPrefixLanguageExtractor,
+	"RouteParamName": RouteParamLanguageExtractor,
+}
+
+// Validate checks the LanguageExtractorOptions, so that a typo ("CookieNam")
+// is caught at startup rather than leaving an extractor broken at runtime:
+// every key must be known, i.e. used by the built-in extractors or listed
+// in ExtractorOptionKeys, and every built-in extractor of
+// LanguageExtractors must find its option.
+func (t *Translator) Validate() error {
+	known := map[string]bool{
+		AvailableLanguagesOption: true,
+		LogLevelOption:           true,
+		SessionValueOption:       true,
+	}
+	for key := range extractorOptionKeys {
+		known[key] = true
+	}
+	for _, key := range t.ExtractorOptionKeys {
+		known[key] = true
+	}
+
+	var problems []string
+	unknown := []string{}
+	for key := range t.LanguageExtractorOptions {
+		if !known[key] {
+			unknown = append(unknown, fmt.Sprintf("%q", key))
+		}
+	}
+	sort.Strings(unknown)
+	if len(unknown) > 0 {
+		problems = append(problems, fmt.Sprintf("unknown LanguageExtractorOptions %s", strings.Join(unknown, ", ")))
+	}
+
+	keys := make([]string, 0, len(extractorOptionKeys))
+	for key := range extractorOptionKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !t.usesExtractor(extractorOptionKeys[key]) {
+			continue
+		}
+		if v, _ := t.LanguageExtractorOptions[key].(string); v == "" {
+			problems = append(problems, fmt.Sprintf("%s needs the %q option", extractorName(extractorOptionKeys[key]), key))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("i18n: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// usesExtractor tells whether extractor is one of t.LanguageExtractors.
+func (t *Translator) usesExtractor(extractor LanguageExtractor) bool {
+	for _, e := range t.LanguageExtractors {
+		if reflect.ValueOf(e).Pointer() == reflect.ValueOf(extractor).Pointer() {
+			return true
+		}
+	}
+	return false
+}